@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	registerproto "github.com/HailoOSS/discovery-service/proto/register"
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	unregisterproto "github.com/HailoOSS/discovery-service/proto/unregister"
+)
+
+// ErrNotAuthorised is returned when a caller tries to register or unregister an instance other than itself
+var ErrNotAuthorised = fmt.Errorf("Only an instance itself, or an admin, may register or unregister it")
+
+// Caller identifies who made a request, from its authentication scope; we never trust the
+// client-supplied "from" service
+type Caller struct {
+	// Id is the authenticated identity of the caller, which for an instance is its instance ID
+	Id string
+	// Admin is set when the caller is authenticated with an admin role
+	Admin bool
+}
+
+// callerOf returns who made a request
+func callerOf(req *server.Request) *Caller {
+	c := &Caller{}
+	scope := req.Auth()
+	if scope == nil || !scope.IsAuth() {
+		return c
+	}
+	if user := scope.AuthUser(); user != nil {
+		c.Id = user.Id
+	}
+	for _, role := range AdminRoles {
+		if scope.HasAccess(role) {
+			c.Admin = true
+			break
+		}
+	}
+	return c
+}
+
+// InstanceAuthoriser decides whether a caller may register or unregister an instance, and whether a
+// caller it lets unregister an instance is evicting it rather than the instance leaving of its own accord
+type InstanceAuthoriser interface {
+	Authorise(caller *Caller, inst *registry.Instance) error
+	Evicts(caller *Caller, inst *registry.Instance) bool
+}
+
+// InstanceAuthoriserFunc lets an ordinary function be used as an InstanceAuthoriser, deciding evictions
+// with NotSelf
+type InstanceAuthoriserFunc func(caller *Caller, inst *registry.Instance) error
+
+func (f InstanceAuthoriserFunc) Authorise(caller *Caller, inst *registry.Instance) error {
+	return f(caller, inst)
+}
+
+func (f InstanceAuthoriserFunc) Evicts(caller *Caller, inst *registry.Instance) bool {
+	return NotSelf(caller, inst)
+}
+
+var (
+	// AdminRoles may register and unregister any instance
+	AdminRoles = []string{"ADMIN"}
+	// Authoriser is consulted by the multiregister and unregister endpoint authorisers, and may be
+	// replaced to change who is allowed to do what
+	Authoriser InstanceAuthoriser = InstanceAuthoriserFunc(SelfOrAdmin)
+)
+
+// SelfOrAdmin only lets instances (un)register themselves: the caller must be authenticated as the
+// instance, or be an admin
+func SelfOrAdmin(caller *Caller, inst *registry.Instance) error {
+	if caller.Admin || (caller.Id != "" && caller.Id == inst.Id) {
+		return nil
+	}
+	return ErrNotAuthorised
+}
+
+// NotSelf treats anyone other than the instance itself as evicting it
+func NotSelf(caller *Caller, inst *registry.Instance) bool {
+	return caller.Id != inst.Id
+}
+
+// InstanceEndpointAuthoriser returns the endpoint authoriser for multiregister and unregister, which
+// checks the caller against Authoriser for the instance named in the request before the handler runs
+func InstanceEndpointAuthoriser(code string) server.Authoriser {
+	return &instanceEndpointAuthoriser{code: code}
+}
+
+type instanceEndpointAuthoriser struct {
+	code string
+}
+
+func (a *instanceEndpointAuthoriser) Authorise(req *server.Request) errors.Error {
+	return authoriseRequest(callerOf(req), a.code, req.Data())
+}
+
+// authoriseRequest checks that caller may act on the instance a request refers to, failing closed
+func authoriseRequest(caller *Caller, code string, data proto.Message) errors.Error {
+	switch request := data.(type) {
+	case *registerproto.MultiRequest:
+		inst := multiRegToInstance(request)
+		if err := authorise(caller, code, inst); err != nil {
+			return err
+		}
+		if !registry.ValidNodeName(inst.Id) {
+			// nothing to take over; the handler rejects it
+			return nil
+		}
+		// don't let anyone take over an existing instance ID either
+		existing, err := getInstance(inst.Id)
+		switch err {
+		case nil:
+			return authorise(caller, code, existing)
+		case registry.ErrInstanceNotFound:
+			return nil
+		}
+		return errors.InternalServerError(code, fmt.Sprintf("Error reading instance: %v", err))
+	case *unregisterproto.Request:
+		inst, err := getInstance(request.GetInstanceId())
+		switch err {
+		case nil:
+			return authorise(caller, code, inst)
+		case registry.ErrInstanceNotFound:
+			return errors.NotFound(code+".notfound", fmt.Sprintf("%v: %v", err, request.GetInstanceId()))
+		}
+		return errors.InternalServerError(code, fmt.Sprintf("Error reading instance: %v", err))
+	}
+	return errors.Forbidden(code+".forbidden", fmt.Sprintf("Unexpected request %T", data))
+}
+
+// authorise checks that caller may act on inst, returning a forbidden error if not
+func authorise(caller *Caller, code string, inst *registry.Instance) errors.Error {
+	if err := Authoriser.Authorise(caller, inst); err != nil {
+		log.Warnf("[Discovery] Refusing %v of %v (%v) for %q", code, inst.Id, inst.Name, caller.Id)
+		return errors.Forbidden(code+".forbidden", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	registerproto "github.com/HailoOSS/discovery-service/proto/register"
+	"github.com/HailoOSS/discovery-service/registry"
+
+	unregisterproto "github.com/HailoOSS/discovery-service/proto/unregister"
+)
+
+func TestSelfOrAdmin(t *testing.T) {
+	inst := &registry.Instance{
+		Id:   "instance-1",
+		Name: "com.HailoOSS.service.foo",
+	}
+
+	testCases := []struct {
+		desc    string
+		caller  *Caller
+		allowed bool
+	}{
+		{"itself", &Caller{Id: "instance-1"}, true},
+		{"admin", &Caller{Id: "tool", Admin: true}, true},
+		{"admin without an identity", &Caller{Admin: true}, true},
+		{"another instance", &Caller{Id: "instance-2"}, false},
+		{"an instance sharing a prefix", &Caller{Id: "instance-10"}, false},
+		{"the service's name", &Caller{Id: "com.HailoOSS.service.foo"}, false},
+		{"anonymous", &Caller{}, false},
+	}
+
+	for _, tc := range testCases {
+		err := SelfOrAdmin(tc.caller, inst)
+		if tc.allowed && err != nil {
+			t.Errorf("%v: expected to be allowed, got %v", tc.desc, err)
+		}
+		if !tc.allowed && err != ErrNotAuthorised {
+			t.Errorf("%v: expected ErrNotAuthorised, got %v", tc.desc, err)
+		}
+	}
+}
+
+func TestSelfOrAdminAnonymousInstance(t *testing.T) {
+	// an instance without an ID mustn't be up for grabs by callers without an identity
+	if err := SelfOrAdmin(&Caller{}, &registry.Instance{Name: "com.HailoOSS.service.foo"}); err != ErrNotAuthorised {
+		t.Errorf("Expected ErrNotAuthorised, got %v", err)
+	}
+}
+
+func TestPluggableAuthoriser(t *testing.T) {
+	defer func(a InstanceAuthoriser) { Authoriser = a }(Authoriser)
+
+	var seen *Caller
+	Authoriser = InstanceAuthoriserFunc(func(caller *Caller, inst *registry.Instance) error {
+		seen = caller
+		return ErrNotAuthorised
+	})
+
+	caller := &Caller{Id: "instance-1", Admin: true}
+	if err := Authoriser.Authorise(caller, &registry.Instance{Name: "com.HailoOSS.service.foo"}); err != ErrNotAuthorised {
+		t.Errorf("Expected replacement authoriser to refuse, got %v", err)
+	}
+	if seen != caller {
+		t.Errorf("Expected replacement authoriser to be called with %v, got %v", caller, seen)
+	}
+}
+
+func TestAuthoriseRequest(t *testing.T) {
+	_, restore := stubRegistry(&registry.Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"})
+	defer restore()
+
+	register := func(id string) proto.Message {
+		return &registerproto.MultiRequest{InstanceId: proto.String(id)}
+	}
+
+	testCases := []struct {
+		desc   string
+		caller *Caller
+		req    proto.Message
+		code   string
+	}{
+		{"register itself", &Caller{Id: "instance-2"}, register("instance-2"), ""},
+		{"register another instance", &Caller{Id: "instance-2"}, register("instance-3"), "test.forbidden"},
+		{"take over an existing instance", &Caller{Id: "instance-2"}, register("instance-1"), "test.forbidden"},
+		{"re-register itself", &Caller{Id: "instance-1"}, register("instance-1"), ""},
+		{"anonymous register", &Caller{}, register("instance-2"), "test.forbidden"},
+		{"admin register", &Caller{Admin: true}, register("instance-3"), ""},
+		{"unregister itself", &Caller{Id: "instance-1"}, unregisterRequest("instance-1"), ""},
+		{"unregister another instance", &Caller{Id: "instance-2"}, unregisterRequest("instance-1"), "test.forbidden"},
+		{"unregister an unknown instance", &Caller{Id: "instance-2"}, unregisterRequest("instance-2"), "test.notfound"},
+		{"admin unregister an unknown instance", &Caller{Admin: true}, unregisterRequest("instance-2"), "test.notfound"},
+		{"unexpected request", &Caller{Admin: true}, &unregisterproto.Response{}, "test.forbidden"},
+	}
+
+	for _, tc := range testCases {
+		err := authoriseRequest(tc.caller, "test", tc.req)
+		switch {
+		case tc.code == "" && err != nil:
+			t.Errorf("%v: expected to be allowed, got %v", tc.desc, err)
+		case tc.code != "" && (err == nil || err.Code() != tc.code):
+			t.Errorf("%v: expected %v, got %v", tc.desc, tc.code, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	createruleproto "github.com/HailoOSS/discovery-service/proto/createrule"
+)
+
+// CreateRule adds a rule pinning a percentage of a service's callers to a version
+func CreateRule(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*createruleproto.Request)
+
+	rule, err := registry.CreateRule(protoToRule(request.GetRule()))
+	if err != nil {
+		return nil, ruleError("com.HailoOSS.kernel.discovery.createrule", err)
+	}
+
+	return &createruleproto.Response{
+		Rule: ruleToProto(rule),
+	}, nil
+}
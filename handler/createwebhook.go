@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	createwebhookproto "github.com/HailoOSS/discovery-service/proto/createwebhook"
+)
+
+// CreateWebhook registers an HTTP endpoint to receive serviceup/servicedown events
+func CreateWebhook(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*createwebhookproto.Request)
+
+	hook, err := registry.CreateWebhook(&registry.Webhook{
+		Url:           request.GetWebhook().GetUrl(),
+		Secret:        request.GetWebhook().GetSecret(),
+		ServicePrefix: request.GetWebhook().GetServicePrefix(),
+	})
+	if err == registry.ErrInvalidWebhook {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.createwebhook.invalid", err.Error())
+	} else if err == registry.ErrWebhookInsecure {
+		return nil, errors.Forbidden("com.HailoOSS.kernel.discovery.createwebhook.insecure", err.Error())
+	} else if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.createwebhook", fmt.Sprintf("Error creating webhook: %v", err))
+	}
+
+	return &createwebhookproto.Response{
+		Webhook: webhookToProto(hook),
+	}, nil
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	deleteruleproto "github.com/HailoOSS/discovery-service/proto/deleterule"
+)
+
+// DeleteRule removes a routing rule
+func DeleteRule(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*deleteruleproto.Request)
+
+	if err := registry.DeleteRule(request.GetService(), request.GetId()); err != nil {
+		return nil, ruleError("com.HailoOSS.kernel.discovery.deleterule", err)
+	}
+
+	return &deleteruleproto.Response{}, nil
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	deletewebhookproto "github.com/HailoOSS/discovery-service/proto/deletewebhook"
+)
+
+// DeleteWebhook stops a webhook receiving events
+func DeleteWebhook(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*deletewebhookproto.Request)
+
+	err := registry.DeleteWebhook(request.GetId())
+	if err == registry.ErrWebhookNotFound {
+		return nil, errors.NotFound("com.HailoOSS.kernel.discovery.deletewebhook.notfound", err.Error())
+	} else if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.deletewebhook", fmt.Sprintf("Error deleting webhook: %v", err))
+	}
+
+	return &deletewebhookproto.Response{}, nil
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	drainproto "github.com/HailoOSS/discovery-service/proto/drain"
+)
+
+// Drain stops an instance being sent new traffic whilst leaving it registered, eg: ahead of
+// shutting it down, or undrains it again
+func Drain(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*drainproto.Request)
+	instanceId := request.GetInstanceId()
+
+	switch err := registry.SetDrained(instanceId, request.GetDrained()); err {
+	case nil:
+	case registry.ErrInstanceNotFound:
+		return nil, errors.NotFound("com.HailoOSS.kernel.discovery.drain.notfound", fmt.Sprintf("%v: %v", err, instanceId))
+	default:
+		log.Warnf("[Discovery] Error draining %v: %v", instanceId, err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.drain", fmt.Sprintf("Error draining: %v", err))
+	}
+
+	return &drainproto.Response{}, nil
+}
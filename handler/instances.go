@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/HailoOSS/protobuf/proto"
 
 	"github.com/HailoOSS/discovery-service/registry"
@@ -10,11 +12,15 @@ import (
 	instancesproto "github.com/HailoOSS/discovery-service/proto/instances"
 )
 
-// Instances returns instances, optionally just matching an AZ name
+// Instances returns instances, optionally just matching an AZ name, within our region by default
+// or within a named region (or "all" regions) that we are federated with
 func Instances(req *server.Request) (proto.Message, errors.Error) {
 	request := req.Data().(*instancesproto.Request)
 
-	instances := registry.AllInstances()
+	instances, err := registry.RegionInstances(request.GetRegion())
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.instances.region", fmt.Sprintf("%v: %v", err, request.GetRegion()))
+	}
 	if az := request.GetAzName(); az != "" {
 		instances = instances.Filter(registry.MatchingAz(az))
 	}
@@ -24,5 +30,6 @@ func Instances(req *server.Request) (proto.Message, errors.Error) {
 
 	return &instancesproto.Response{
 		Instances: instancesToProto(instances),
+		Stale:     proto.Bool(registry.RegionStale(request.GetRegion())),
 	}, nil
 }
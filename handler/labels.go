@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	labelsproto "github.com/HailoOSS/discovery-service/proto/labels"
+)
+
+// Labels adds or changes labels of an instance without it re-registering; a label with an empty
+// value is removed
+func Labels(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*labelsproto.Request)
+	instanceId := request.GetInstanceId()
+
+	labels := make(map[string]string, len(request.GetLabels()))
+	for _, l := range request.GetLabels() {
+		labels[l.GetKey()] = l.GetValue()
+	}
+
+	switch err := registry.SetLabels(instanceId, labels); err {
+	case nil:
+	case registry.ErrInvalidLabels:
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.labels.invalid", err.Error())
+	case registry.ErrInstanceNotFound:
+		return nil, errors.NotFound("com.HailoOSS.kernel.discovery.labels.notfound", fmt.Sprintf("%v: %v", err, instanceId))
+	default:
+		log.Warnf("[Discovery] Error setting labels of %v: %v", instanceId, err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.labels", fmt.Sprintf("Error setting labels: %v", err))
+	}
+
+	return &labelsproto.Response{}, nil
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	leaderproto "github.com/HailoOSS/discovery-service/proto/leader"
+)
+
+// Leader returns which discovery node is currently leader within the region, plus all nodes taking part
+func Leader(req *server.Request) (proto.Message, errors.Error) {
+	nodes, err := registry.Nodes()
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.leader", fmt.Sprintf("Error reading nodes: %v", err))
+	}
+	if len(nodes) == 0 {
+		return nil, errors.NotFound("com.HailoOSS.kernel.discovery.leader.notfound", registry.ErrNoLeader.Error())
+	}
+
+	return &leaderproto.Response{
+		LeaderId: proto.String(nodes[0].Id),
+		Hostname: proto.String(nodes[0].Hostname),
+		Nodes:    nodesToProto(nodes),
+	}, nil
+}
@@ -2,10 +2,18 @@ package handler
 
 import (
 	"fmt"
+	"sort"
+
 	commonproto "github.com/HailoOSS/discovery-service/proto"
 	endpoints "github.com/HailoOSS/discovery-service/proto/endpoints"
 	instances "github.com/HailoOSS/discovery-service/proto/instances"
+	leader "github.com/HailoOSS/discovery-service/proto/leader"
+	owners "github.com/HailoOSS/discovery-service/proto/owners"
 	register "github.com/HailoOSS/discovery-service/proto/register"
+	replay "github.com/HailoOSS/discovery-service/proto/replay"
+	subscribers "github.com/HailoOSS/discovery-service/proto/subscribers"
+	summary "github.com/HailoOSS/discovery-service/proto/summary"
+	versions "github.com/HailoOSS/discovery-service/proto/versions"
 	"github.com/HailoOSS/discovery-service/registry"
 	"github.com/HailoOSS/protobuf/proto"
 )
@@ -24,8 +32,15 @@ func multiRegToInstance(request *register.MultiRequest) *registry.Instance {
 		OwnerEmail:   request.GetService().GetOwnerEmail(),
 		OwnerMobile:  request.GetService().GetOwnerMobile(),
 		OwnerTeam:    request.GetService().GetOwnerTeam(),
+		Weight:       request.GetWeight(),
 		Endpoints:    make([]*registry.Endpoint, 0),
 	}
+	if len(request.GetLabels()) > 0 {
+		inst.Labels = make(map[string]string, len(request.GetLabels()))
+		for _, l := range request.GetLabels() {
+			inst.Labels[l.GetKey()] = l.GetValue()
+		}
+	}
 	for _, endpoint := range request.GetEndpoints() {
 		inst.Endpoints = append(inst.Endpoints, &registry.Endpoint{
 			Name:      endpoint.GetName(),
@@ -97,6 +112,9 @@ func instancesToProto(insts registry.Instances) []*instances.Instance {
 			ServiceVersion:     proto.Uint64(inst.Version),
 			AzName:             proto.String(inst.AzName),
 			SubTopic:           make([]string, 0),
+			Region:             proto.String(inst.Region),
+			Weight:             proto.Uint32(inst.GetWeight()),
+			Labels:             labelsToProto(inst.Labels),
 		}
 		for _, ep := range inst.Endpoints {
 			if ep.Subscribe != "" {
@@ -107,3 +125,234 @@ func instancesToProto(insts registry.Instances) []*instances.Instance {
 	}
 	return ret
 }
+
+// labelsToProto turns labels into proto format, ordered by key
+func labelsToProto(labels map[string]string) []*commonproto.Label {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := make([]*commonproto.Label, len(keys))
+	for i, k := range keys {
+		ret[i] = &commonproto.Label{
+			Key:   proto.String(k),
+			Value: proto.String(labels[k]),
+		}
+	}
+	return ret
+}
+
+// nodesToProto turns discovery nodes into proto format
+func nodesToProto(nodes []*registry.Node) []*leader.Response_Node {
+	ret := make([]*leader.Response_Node, 0)
+	for _, n := range nodes {
+		ret = append(ret, &leader.Response_Node{
+			Id:       proto.String(n.Id),
+			Hostname: proto.String(n.Hostname),
+			Leader:   proto.Bool(n.Leader),
+		})
+	}
+	return ret
+}
+
+// ruleToProto marshals a routing rule to proto
+func ruleToProto(r *registry.Rule) *commonproto.Rule {
+	return &commonproto.Rule{
+		Id:           proto.String(r.Id),
+		Service:      proto.String(r.Service),
+		Version:      proto.Uint64(r.Version),
+		Percentage:   proto.Uint32(r.Percentage),
+		CallerPrefix: proto.String(r.CallerPrefix),
+	}
+}
+
+// rulesToProto marshals routing rules to proto
+func rulesToProto(rules []*registry.Rule) []*commonproto.Rule {
+	ret := make([]*commonproto.Rule, 0)
+	for _, r := range rules {
+		ret = append(ret, ruleToProto(r))
+	}
+	return ret
+}
+
+// protoToRule unmarshals a routing rule from proto
+func protoToRule(r *commonproto.Rule) *registry.Rule {
+	return &registry.Rule{
+		Id:           r.GetId(),
+		Service:      r.GetService(),
+		Version:      r.GetVersion(),
+		Percentage:   r.GetPercentage(),
+		CallerPrefix: r.GetCallerPrefix(),
+	}
+}
+
+// webhookToProto marshals a webhook to proto, leaving out its secret
+func webhookToProto(h *registry.Webhook) *commonproto.Webhook {
+	return &commonproto.Webhook{
+		Id:            proto.String(h.Id),
+		Url:           proto.String(h.Url),
+		ServicePrefix: proto.String(h.ServicePrefix),
+	}
+}
+
+// webhooksToProto marshals webhooks to proto, leaving out their secrets
+func webhooksToProto(hooks []*registry.Webhook) []*commonproto.Webhook {
+	ret := make([]*commonproto.Webhook, 0)
+	for _, h := range hooks {
+		ret = append(ret, webhookToProto(h))
+	}
+	return ret
+}
+
+var eventTypeToProto = map[registry.EventType]replay.Response_Event_Type{
+	registry.EventUp:      replay.Response_Event_UP,
+	registry.EventDown:    replay.Response_Event_DOWN,
+	registry.EventDrained: replay.Response_Event_DRAINED,
+	registry.EventSuspect: replay.Response_Event_SUSPECT,
+	registry.EventUpdated: replay.Response_Event_UPDATED,
+}
+
+// eventsToProto marshals journalled events to proto
+func eventsToProto(events []*registry.Event) []*replay.Response_Event {
+	ret := make([]*replay.Response_Event, 0)
+	for _, e := range events {
+		protoEvent := &replay.Response_Event{
+			Seq:            proto.Uint64(e.Seq),
+			Timestamp:      proto.Int64(e.Timestamp.Unix()),
+			Type:           eventTypeToProto[e.Type].Enum(),
+			InstanceId:     proto.String(e.InstanceId),
+			Hostname:       proto.String(e.Hostname),
+			ServiceName:    proto.String(e.ServiceName),
+			ServiceVersion: proto.Uint64(e.ServiceVersion),
+			AzName:         proto.String(e.AzName),
+		}
+		if e.Type == registry.EventDown {
+			protoEvent.Reason = proto.String(e.Reason.String())
+		}
+		ret = append(ret, protoEvent)
+	}
+	return ret
+}
+
+// summaryToProto marshals a census of the region to proto, with counts ordered by name
+func summaryToProto(s *registry.Summary) *summary.Response {
+	rsp := &summary.Response{
+		Revision:       proto.Uint64(s.Revision),
+		Instances:      proto.Uint32(uint32(s.Instances)),
+		Hosts:          proto.Uint32(uint32(s.Hosts)),
+		Nodes:          proto.Uint32(uint32(s.Nodes)),
+		Services:       countsToProto(s.Services),
+		Versions:       make([]*summary.Response_VersionCount, 0),
+		AzNames:        countsToProto(s.AzNames),
+		MachineClasses: countsToProto(s.MachineClasses),
+		Stale:          proto.Bool(s.Stale),
+		NodesUnknown:   proto.Bool(!s.NodesCounted),
+	}
+	for _, c := range rsp.Services {
+		counts := s.Versions[c.GetName()]
+		vs := make([]uint64, 0, len(counts))
+		for v := range counts {
+			vs = append(vs, v)
+		}
+		sort.Sort(uint64Slice(vs))
+		for _, v := range vs {
+			rsp.Versions = append(rsp.Versions, &summary.Response_VersionCount{
+				Service: proto.String(c.GetName()),
+				Version: proto.Uint64(v),
+				Count:   proto.Uint32(uint32(counts[v])),
+			})
+		}
+	}
+	return rsp
+}
+
+// countsToProto marshals counts to proto, ordered by name
+func countsToProto(counts map[string]int) []*summary.Response_Count {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := make([]*summary.Response_Count, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, &summary.Response_Count{
+			Name:  proto.String(name),
+			Count: proto.Uint32(uint32(counts[name])),
+		})
+	}
+	return ret
+}
+
+// versionsToProto marshals versions of a service to proto, with AZs ordered by name
+func versionsToProto(vs []*registry.ServiceVersion) []*versions.Response_Version {
+	ret := make([]*versions.Response_Version, 0, len(vs))
+	for _, v := range vs {
+		azs := make([]*versions.Response_AzCount, 0, len(v.AzNames))
+		for _, c := range countsToProto(v.AzNames) {
+			azs = append(azs, &versions.Response_AzCount{
+				AzName: c.Name,
+				Count:  c.Count,
+			})
+		}
+		ret = append(ret, &versions.Response_Version{
+			Version:   proto.Uint64(v.Version),
+			Instances: proto.Uint32(uint32(v.Instances)),
+			AzNames:   azs,
+			FirstSeen: proto.Int64(v.FirstSeen.Unix()),
+			Newest:    proto.Bool(v.Newest),
+		})
+	}
+	return ret
+}
+
+// subscriptionsToProto marshals subscriptions to a topic to proto
+func subscriptionsToProto(subs []*registry.Subscription) []*subscribers.Response_Subscriber {
+	ret := make([]*subscribers.Response_Subscriber, 0, len(subs))
+	for _, sub := range subs {
+		ret = append(ret, &subscribers.Response_Subscriber{
+			Topic:          proto.String(sub.Topic),
+			ServiceName:    proto.String(sub.ServiceName),
+			ServiceVersion: proto.Uint64(sub.ServiceVersion),
+			EndpointName:   proto.String(sub.EndpointName),
+			InstanceIds:    sub.InstanceIds,
+		})
+	}
+	return ret
+}
+
+// teamsToProto marshals teams, and what they own, to proto
+func teamsToProto(teams []*registry.Team) []*owners.Response_Team {
+	ret := make([]*owners.Response_Team, 0, len(teams))
+	for _, team := range teams {
+		t := &owners.Response_Team{
+			Name:     proto.String(team.Name),
+			Contacts: make([]*owners.Response_Contact, 0, len(team.Contacts)),
+		}
+		for _, contact := range team.Contacts {
+			c := &owners.Response_Contact{
+				Email:    proto.String(contact.Email),
+				Mobile:   proto.String(contact.Mobile),
+				Services: make([]*owners.Response_Service, 0, len(contact.Services)),
+			}
+			for _, svc := range contact.Services {
+				c.Services = append(c.Services, &owners.Response_Service{
+					Name:      proto.String(svc.Name),
+					Versions:  svc.Versions,
+					Instances: proto.Uint32(uint32(svc.Instances)),
+				})
+			}
+			t.Contacts = append(t.Contacts, c)
+		}
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -16,8 +16,21 @@ import (
 func MultiRegister(req *server.Request) (proto.Message, errors.Error) {
 	request := req.Data().(*registerproto.MultiRequest)
 
+	if err := validateRegistration(request); err != nil {
+		log.Warnf("[Discovery] Rejecting registration of %v: %v", request.GetInstanceId(), err.Description())
+		return nil, err
+	}
+
+	// the endpoint's authoriser has already checked the caller may register this instance
 	inst := multiRegToInstance(request)
-	if err := registry.Register(inst); err != nil {
+
+	register := registry.Register
+	if request.GetUpdate() {
+		register = registry.UpdateRegistration
+	}
+	if err := register(inst); err == registry.ErrRegistrationConflict {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.multiregister.conflict", err.Error())
+	} else if err != nil {
 		log.Warnf("[Discovery] Error registering endpoint: %s", err.Error())
 		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.multiregister", fmt.Sprintf("Error registering: %v", err))
 	}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	ownersproto "github.com/HailoOSS/discovery-service/proto/owners"
+)
+
+// Owners returns running services grouped by owning team and contact, optionally just those
+// belonging to one team and/or email address
+func Owners(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*ownersproto.Request)
+
+	instances := registry.AllInstances()
+	if team := request.GetTeam(); team != "" {
+		instances = instances.Filter(registry.MatchingOwnerTeam(team))
+	}
+	if email := request.GetEmail(); email != "" {
+		instances = instances.Filter(registry.MatchingOwnerEmail(email))
+	}
+
+	return &ownersproto.Response{
+		Teams: teamsToProto(instances.Owners()),
+	}, nil
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	replayproto "github.com/HailoOSS/discovery-service/proto/replay"
+)
+
+// Replay returns journalled registry events after a sequence number and/or timestamp, so that consumers
+// which were down can catch up. Sequence numbers are only meaningful for the node that returned them,
+// so use timestamps if you might be talking to a different discovery node.
+func Replay(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*replayproto.Request)
+
+	var since time.Time
+	if ts := request.GetSinceTimestamp(); ts > 0 {
+		since = time.Unix(ts, 0)
+	}
+
+	events, lastSeq, err := registry.Replay(request.GetSinceSeq(), since, int(request.GetLimit()))
+	if err != nil {
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.replay", fmt.Sprintf("Error replaying events: %v", err))
+	}
+
+	return &replayproto.Response{
+		Events:  eventsToProto(events),
+		LastSeq: proto.Uint64(lastSeq),
+		NodeId:  proto.String(registry.NodeId()),
+	}, nil
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	resolveproto "github.com/HailoOSS/discovery-service/proto/resolve"
+)
+
+// Resolve returns the version(s) of a service that a caller should use, according to routing rules
+func Resolve(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*resolveproto.Request)
+
+	if request.GetService() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.resolve.service", "Service is required")
+	}
+
+	// rules match on the calling service, but split its instances between versions, so callers stay
+	// sticky by their own identity: the one supplied, otherwise the one they authenticated as
+	caller := request.GetCaller()
+	if caller == "" {
+		caller = req.From()
+	}
+	callerId := request.GetCallerId()
+	if callerId == "" {
+		callerId = callerOf(req).Id
+	}
+
+	versions, rule := registry.Resolve(request.GetService(), caller, callerId)
+	rsp := &resolveproto.Response{
+		Versions: versions,
+	}
+	if rule != nil {
+		rsp.RuleId = proto.String(rule.Id)
+	}
+
+	return rsp, nil
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	rulesproto "github.com/HailoOSS/discovery-service/proto/rules"
+)
+
+// Rules returns all version routing rules, optionally just for one service
+func Rules(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*rulesproto.Request)
+
+	return &rulesproto.Response{
+		Rules: rulesToProto(registry.Rules(request.GetService())),
+	}, nil
+}
+
+// ruleError turns an error from modifying routing rules into a platform error
+func ruleError(code string, err error) errors.Error {
+	if _, ok := err.(registry.InvalidRuleError); ok {
+		return errors.BadRequest(code+".invalid", err.Error())
+	}
+	switch err {
+	case registry.ErrRuleNotFound:
+		return errors.NotFound(code+".notfound", err.Error())
+	case registry.ErrRuleConflict:
+		return errors.InternalServerError(code+".conflict", err.Error())
+	}
+	log.Warnf("[Discovery] Error modifying routing rules: %v", err)
+	return errors.InternalServerError(code, fmt.Sprintf("Error modifying routing rules: %v", err))
+}
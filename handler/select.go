@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	selectproto "github.com/HailoOSS/discovery-service/proto/select"
+)
+
+// Select returns candidate instances of a service, ordered by preference for a caller within the
+// supplied AZ; the order within an AZ is random, biased by weight, so that clients taking the first
+// candidate spread their load in proportion to weight
+func Select(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*selectproto.Request)
+
+	if request.GetServiceName() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.select.servicename", "Service name is required")
+	}
+
+	instances := registry.Candidates(request.GetServiceName(), request.GetAzName())
+	if limit := int(request.GetLimit()); limit > 0 && len(instances) > limit {
+		instances = instances[:limit]
+	}
+
+	return &selectproto.Response{
+		Instances: instancesToProto(instances),
+	}, nil
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/HailoOSS/protobuf/proto"
 
 	"github.com/HailoOSS/discovery-service/registry"
@@ -10,16 +12,21 @@ import (
 	servicesproto "github.com/HailoOSS/discovery-service/proto/services"
 )
 
-// Services returns a list of services running in the region
+// Services returns a list of services running in the region, or within a named region (or "all"
+// regions) that we are federated with
 func Services(req *server.Request) (proto.Message, errors.Error) {
 	request := req.Data().(*servicesproto.Request)
 
-	instances := registry.AllInstances()
+	instances, err := registry.RegionInstances(request.GetRegion())
+	if err != nil {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.services.region", fmt.Sprintf("%v: %v", err, request.GetRegion()))
+	}
 	if service := request.GetService(); service != "" {
 		instances = instances.Filter(registry.MatchingService(service))
 	}
 
 	return &servicesproto.Response{
 		Services: instancesToServicesProto(instances),
+		Stale:    proto.Bool(registry.RegionStale(request.GetRegion())),
 	}, nil
 }
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	subscribersproto "github.com/HailoOSS/discovery-service/proto/subscribers"
+)
+
+// Subscribers returns the services, versions and instances subscribing to a topic (or any topic
+// with a given prefix), to see who would be affected by changing what is published on it
+func Subscribers(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*subscribersproto.Request)
+
+	if request.GetTopic() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.subscribers.topic", "Topic is required")
+	}
+
+	subs := registry.AllInstances().Subscribers(request.GetTopic(), request.GetPrefix())
+
+	return &subscribersproto.Response{
+		Subscribers: subscriptionsToProto(subs),
+	}, nil
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+)
+
+// Summary returns a census of the region: how many instances are running of each service, version,
+// AZ and machine class, plus how many hosts and discovery nodes there are (marked unknown until we
+// have counted them)
+func Summary(req *server.Request) (proto.Message, errors.Error) {
+	return summaryToProto(registry.RegionSummary()), nil
+}
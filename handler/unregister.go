@@ -13,12 +13,38 @@ import (
 	unregisterproto "github.com/HailoOSS/discovery-service/proto/unregister"
 )
 
+var (
+	// getInstance, unregisterInstance and evictInstance are the registry calls unregister and its
+	// authoriser make
+	getInstance        = registry.GetInstance
+	unregisterInstance = registry.Unregister
+	evictInstance      = registry.Evict
+)
+
 // Unregister removes a service from the discovery service
 func Unregister(req *server.Request) (proto.Message, errors.Error) {
-	request := req.Data().(*unregisterproto.Request)
+	return unregister(callerOf(req), req.Data().(*unregisterproto.Request))
+}
+
+func unregister(caller *Caller, request *unregisterproto.Request) (proto.Message, errors.Error) {
 	instanceId := request.GetInstanceId()
 
-	if err := registry.Unregister(instanceId); err != nil {
+	// the endpoint's authoriser has already checked the caller may unregister this instance, so
+	// all that's left is whether they're evicting it
+	inst, err := getInstance(instanceId)
+	switch err {
+	case nil:
+	case registry.ErrInstanceNotFound:
+		return nil, errors.NotFound("com.HailoOSS.kernel.discovery.unregister.notfound", fmt.Sprintf("%v: %v", err, instanceId))
+	default:
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.unregister", fmt.Sprintf("Error reading instance: %v", err))
+	}
+
+	remove := unregisterInstance
+	if Authoriser.Evicts(caller, inst) {
+		remove = evictInstance
+	}
+	if err := remove(instanceId); err != nil {
 		log.Warnf("[Discovery] Error unregistering endpoint: %v", err)
 		return nil, errors.InternalServerError("com.HailoOSS.discovery.handler.unregister", fmt.Sprintf("Error unregistering endpoint: %v", err))
 	}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+
+	unregisterproto "github.com/HailoOSS/discovery-service/proto/unregister"
+)
+
+// fakeRegistry holds instances for unregister, recording how each was removed
+type fakeRegistry struct {
+	instances map[string]*registry.Instance
+	removed   map[string]string
+}
+
+func stubRegistry(instances ...*registry.Instance) (*fakeRegistry, func()) {
+	r := &fakeRegistry{
+		instances: make(map[string]*registry.Instance),
+		removed:   make(map[string]string),
+	}
+	for _, inst := range instances {
+		r.instances[inst.Id] = inst
+	}
+
+	g, u, e := getInstance, unregisterInstance, evictInstance
+	getInstance = func(id string) (*registry.Instance, error) {
+		if inst, ok := r.instances[id]; ok {
+			return inst, nil
+		}
+		return nil, registry.ErrInstanceNotFound
+	}
+	unregisterInstance = func(id string) error {
+		r.removed[id] = "unregistered"
+		return nil
+	}
+	evictInstance = func(id string) error {
+		r.removed[id] = "evicted"
+		return nil
+	}
+	return r, func() { getInstance, unregisterInstance, evictInstance = g, u, e }
+}
+
+func unregisterRequest(id string) *unregisterproto.Request {
+	return &unregisterproto.Request{InstanceId: proto.String(id)}
+}
+
+// callUnregister runs the endpoint's authoriser and then its handler, as the platform would
+func callUnregister(caller *Caller, id string) errors.Error {
+	code := "com.HailoOSS.kernel.discovery.unregister"
+	if err := authoriseRequest(caller, code, unregisterRequest(id)); err != nil {
+		return err
+	}
+	_, err := unregister(caller, unregisterRequest(id))
+	return err
+}
+
+func TestUnregisterByAnotherInstance(t *testing.T) {
+	r, restore := stubRegistry(&registry.Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"})
+	defer restore()
+
+	err := callUnregister(&Caller{Id: "instance-2"}, "instance-1")
+	if err == nil || err.Code() != "com.HailoOSS.kernel.discovery.unregister.forbidden" {
+		t.Errorf("Expected forbidden error, got %v", err)
+	}
+	if how, ok := r.removed["instance-1"]; ok {
+		t.Errorf("Expected instance to be left alone, but it was %v", how)
+	}
+}
+
+func TestUnregisterBySelf(t *testing.T) {
+	r, restore := stubRegistry(
+		&registry.Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"},
+		&registry.Instance{Id: "instance-2", Name: "com.HailoOSS.service.foo"},
+	)
+	defer restore()
+
+	if err := callUnregister(&Caller{Id: "instance-1"}, "instance-1"); err != nil {
+		t.Fatalf("Expected instance to be allowed, got %v", err)
+	}
+	if how := r.removed["instance-1"]; how != "unregistered" {
+		t.Errorf("Expected instance to be unregistered rather than evicted, but it was %q", how)
+	}
+	if how, ok := r.removed["instance-2"]; ok {
+		t.Errorf("Expected sibling to be left alone, but it was %v", how)
+	}
+}
+
+func TestUnregisterUnknownInstance(t *testing.T) {
+	r, restore := stubRegistry()
+	defer restore()
+
+	err := callUnregister(&Caller{Id: "instance-1"}, "instance-1")
+	if err == nil || err.Code() != "com.HailoOSS.kernel.discovery.unregister.notfound" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if how, ok := r.removed["instance-1"]; ok {
+		t.Errorf("Expected nothing to be removed, but instance was %v", how)
+	}
+}
+
+func TestUnregisterByAdmin(t *testing.T) {
+	r, restore := stubRegistry(&registry.Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"})
+	defer restore()
+
+	if err := callUnregister(&Caller{Id: "tool", Admin: true}, "instance-1"); err != nil {
+		t.Fatalf("Expected admin to be allowed, got %v", err)
+	}
+	if how := r.removed["instance-1"]; how != "evicted" {
+		t.Errorf("Expected instance to be evicted, but it was %q", how)
+	}
+}
+
+// evictNothing lets anyone unregister anything, never as an eviction
+type evictNothing struct{}
+
+func (evictNothing) Authorise(caller *Caller, inst *registry.Instance) error { return nil }
+func (evictNothing) Evicts(caller *Caller, inst *registry.Instance) bool     { return false }
+
+func TestUnregisterPluggableEviction(t *testing.T) {
+	defer func(a InstanceAuthoriser) { Authoriser = a }(Authoriser)
+	Authoriser = evictNothing{}
+
+	r, restore := stubRegistry(&registry.Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"})
+	defer restore()
+
+	if err := callUnregister(&Caller{Id: "instance-2"}, "instance-1"); err != nil {
+		t.Fatalf("Expected replacement authoriser to allow, got %v", err)
+	}
+	if how := r.removed["instance-1"]; how != "unregistered" {
+		t.Errorf("Expected replacement authoriser's eviction decision to be used, but it was %q", how)
+	}
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	updateruleproto "github.com/HailoOSS/discovery-service/proto/updaterule"
+)
+
+// UpdateRule replaces an existing routing rule, matched on service and ID
+func UpdateRule(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*updateruleproto.Request)
+
+	if err := registry.UpdateRule(protoToRule(request.GetRule())); err != nil {
+		return nil, ruleError("com.HailoOSS.kernel.discovery.updaterule", err)
+	}
+
+	return &updateruleproto.Response{}, nil
+}
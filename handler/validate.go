@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/service/config"
+
+	registerproto "github.com/HailoOSS/discovery-service/proto/register"
+)
+
+// registrationPolicy is what we require of registrations beyond them making sense, configured at
+// hailo.service.discovery.registration
+type registrationPolicy struct {
+	// RequireOwnerTeam rejects services that don't say which team owns them
+	RequireOwnerTeam bool
+	// AllowedPrefixes, if any, are the only prefixes service names may start with
+	AllowedPrefixes []string
+}
+
+func loadRegistrationPolicy() *registrationPolicy {
+	return &registrationPolicy{
+		RequireOwnerTeam: config.AtPath("hailo", "service", "discovery", "registration", "requireOwnerTeam").AsBool(),
+		AllowedPrefixes:  config.AtPath("hailo", "service", "discovery", "registration", "allowedPrefixes").AsStringArray(),
+	}
+}
+
+// registrationViolations returns every way in which a registration is invalid or breaks policy
+func registrationViolations(request *registerproto.MultiRequest, policy *registrationPolicy) []string {
+	var violations []string
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	switch id := request.GetInstanceId(); {
+	case id == "":
+		violate("instanceId is required")
+	case !registry.ValidNodeName(id):
+		violate("instanceId may only contain letters, digits, '.', '_' and '-', and not start with '.'")
+	}
+	if request.GetHostname() == "" {
+		violate("hostname is required")
+	}
+	if request.GetWeight() > registry.MaxWeight {
+		violate("weight must be no more than %v", registry.MaxWeight)
+	}
+
+	service := request.GetService()
+	name := service.GetName()
+	switch {
+	case name == "":
+		violate("service.name is required")
+	case !registry.ValidNodeName(name):
+		violate("service.name may only contain letters, digits, '.', '_' and '-', and not start with '.'")
+	}
+	if service.GetVersion() == 0 {
+		violate("service.version is required")
+	}
+	if policy.RequireOwnerTeam && service.GetOwnerTeam() == "" {
+		violate("service.ownerTeam is required")
+	}
+	if name != "" && len(policy.AllowedPrefixes) > 0 {
+		allowed := false
+		for _, p := range policy.AllowedPrefixes {
+			if strings.HasPrefix(name, p) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violate("service.name must start with one of %v", strings.Join(policy.AllowedPrefixes, ", "))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, ep := range request.GetEndpoints() {
+		switch {
+		case ep.GetName() == "":
+			violate("endpoints[%v].name is required", i)
+		case seen[ep.GetName()]:
+			violate("endpoints[%v].name %v is a duplicate", i, ep.GetName())
+		}
+		seen[ep.GetName()] = true
+
+		if ep.GetMean() < 0 {
+			violate("endpoints[%v].mean must not be negative", i)
+		}
+		if ep.GetUpper95() < 0 {
+			violate("endpoints[%v].upper95 must not be negative", i)
+		}
+	}
+
+	if len(request.GetLabels()) > registry.MaxLabels {
+		violate("labels must number no more than %v", registry.MaxLabels)
+	}
+	seenLabels := make(map[string]bool)
+	for i, l := range request.GetLabels() {
+		switch {
+		case !registry.ValidLabel(l.GetKey(), l.GetValue()):
+			violate("labels[%v] must have a key of letters, digits, '.', '_' and '-', and a value of 1 to %v characters", i, registry.MaxLabelValue)
+		case seenLabels[l.GetKey()]:
+			violate("labels[%v].key %v is a duplicate", i, l.GetKey())
+		}
+		seenLabels[l.GetKey()] = true
+	}
+
+	return violations
+}
+
+// validateRegistration returns a bad request error listing every violation, if there are any, both
+// in the description and as the error's context
+func validateRegistration(request *registerproto.MultiRequest) errors.Error {
+	violations := registrationViolations(request, loadRegistrationPolicy())
+	if len(violations) == 0 {
+		return nil
+	}
+	return errors.BadRequest(
+		"com.HailoOSS.kernel.discovery.multiregister.invalid",
+		fmt.Sprintf("Invalid registration: %v", strings.Join(violations, "; ")),
+		violations...,
+	)
+}
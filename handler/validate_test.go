@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/HailoOSS/protobuf/proto"
+
+	commonproto "github.com/HailoOSS/discovery-service/proto"
+	registerproto "github.com/HailoOSS/discovery-service/proto/register"
+	"github.com/HailoOSS/discovery-service/registry"
+)
+
+// validRegistration returns a registration that breaks no rules, for each test case to spoil
+func validRegistration() *registerproto.MultiRequest {
+	return &registerproto.MultiRequest{
+		InstanceId: proto.String("server-com.HailoOSS.service.foo-0123abcd"),
+		Hostname:   proto.String("host-1"),
+		Service: &commonproto.Service{
+			Name:      proto.String("com.HailoOSS.service.foo"),
+			Version:   proto.Uint64(20140101000000),
+			OwnerTeam: proto.String("platform"),
+		},
+		Endpoints: []*registerproto.MultiRequest_Endpoint{
+			{Name: proto.String("bar"), Mean: proto.Int32(10), Upper95: proto.Int32(20)},
+		},
+	}
+}
+
+func TestRegistrationViolations(t *testing.T) {
+	strict := &registrationPolicy{
+		RequireOwnerTeam: true,
+		AllowedPrefixes:  []string{"com.HailoOSS.service.", "com.HailoOSS.kernel."},
+	}
+
+	testCases := []struct {
+		desc   string
+		policy *registrationPolicy
+		spoil  func(r *registerproto.MultiRequest)
+		// violations expected, by a substring of each
+		violations []string
+	}{
+		{"valid", strict, func(r *registerproto.MultiRequest) {}, nil},
+		{"missing instanceId", strict, func(r *registerproto.MultiRequest) { r.InstanceId = nil }, []string{"instanceId is required"}},
+		{"instanceId with a slash", strict, func(r *registerproto.MultiRequest) { r.InstanceId = proto.String("foo/bar") }, []string{"instanceId may only"}},
+		{"instanceId of ..", strict, func(r *registerproto.MultiRequest) { r.InstanceId = proto.String("..") }, []string{"instanceId may only"}},
+		{"instanceId with a space", strict, func(r *registerproto.MultiRequest) { r.InstanceId = proto.String("foo bar") }, []string{"instanceId may only"}},
+		{"instanceId with unicode", strict, func(r *registerproto.MultiRequest) { r.InstanceId = proto.String("fooé") }, []string{"instanceId may only"}},
+		{"instanceId with a control character", strict, func(r *registerproto.MultiRequest) { r.InstanceId = proto.String("foo\x00") }, []string{"instanceId may only"}},
+		{"instanceId too long", strict, func(r *registerproto.MultiRequest) { r.InstanceId = proto.String(strings.Repeat("a", 256)) }, []string{"instanceId may only"}},
+		{"missing hostname", strict, func(r *registerproto.MultiRequest) { r.Hostname = nil }, []string{"hostname is required"}},
+		{"weight too high", strict, func(r *registerproto.MultiRequest) { r.Weight = proto.Uint32(101) }, []string{"weight must be"}},
+		{"missing service name", strict, func(r *registerproto.MultiRequest) { r.Service.Name = nil }, []string{"service.name is required"}},
+		{"service name with a slash", &registrationPolicy{}, func(r *registerproto.MultiRequest) { r.Service.Name = proto.String("com.HailoOSS/foo") }, []string{"service.name may only"}},
+		{"service name starting with a dot", &registrationPolicy{}, func(r *registerproto.MultiRequest) { r.Service.Name = proto.String(".foo") }, []string{"service.name may only"}},
+		{"missing version", strict, func(r *registerproto.MultiRequest) { r.Service.Version = nil }, []string{"service.version is required"}},
+		{"missing owner team", strict, func(r *registerproto.MultiRequest) { r.Service.OwnerTeam = nil }, []string{"service.ownerTeam is required"}},
+		{"missing owner team, not required", &registrationPolicy{}, func(r *registerproto.MultiRequest) { r.Service.OwnerTeam = nil }, nil},
+		{"disallowed prefix", strict, func(r *registerproto.MultiRequest) { r.Service.Name = proto.String("org.example.foo") }, []string{"service.name must start with"}},
+		{"unnamed endpoint", strict, func(r *registerproto.MultiRequest) { r.Endpoints[0].Name = nil }, []string{"endpoints[0].name is required"}},
+		{"duplicate endpoint", strict, func(r *registerproto.MultiRequest) { r.Endpoints = append(r.Endpoints, r.Endpoints[0]) }, []string{"endpoints[1].name bar is a duplicate"}},
+		{"negative sla", strict, func(r *registerproto.MultiRequest) {
+			r.Endpoints[0].Mean = proto.Int32(-1)
+			r.Endpoints[0].Upper95 = proto.Int32(-1)
+		}, []string{"endpoints[0].mean", "endpoints[0].upper95"}},
+		{"labels", strict, func(r *registerproto.MultiRequest) {
+			r.Labels = []*commonproto.Label{{Key: proto.String("track"), Value: proto.String("canary")}}
+		}, nil},
+		{"label with a slash", strict, func(r *registerproto.MultiRequest) {
+			r.Labels = []*commonproto.Label{{Key: proto.String("track/x"), Value: proto.String("canary")}}
+		}, []string{"labels[0] must have"}},
+		{"label without a value", strict, func(r *registerproto.MultiRequest) {
+			r.Labels = []*commonproto.Label{{Key: proto.String("track"), Value: proto.String("")}}
+		}, []string{"labels[0] must have"}},
+		{"duplicate label", strict, func(r *registerproto.MultiRequest) {
+			l := &commonproto.Label{Key: proto.String("track"), Value: proto.String("canary")}
+			r.Labels = []*commonproto.Label{l, l}
+		}, []string{"labels[1].key track is a duplicate"}},
+		{"too many labels", strict, func(r *registerproto.MultiRequest) {
+			for i := 0; i <= registry.MaxLabels; i++ {
+				r.Labels = append(r.Labels, &commonproto.Label{Key: proto.String(fmt.Sprintf("label-%v", i)), Value: proto.String("x")})
+			}
+		}, []string{"labels must number"}},
+		{"everything wrong at once", strict, func(r *registerproto.MultiRequest) {
+			r.InstanceId, r.Hostname, r.Service = nil, nil, nil
+		}, []string{"instanceId", "hostname", "service.name", "service.version", "service.ownerTeam"}},
+	}
+
+	for _, tc := range testCases {
+		r := validRegistration()
+		tc.spoil(r)
+		violations := registrationViolations(r, tc.policy)
+		if len(violations) != len(tc.violations) {
+			t.Errorf("%v: expected %v violations, got %q", tc.desc, len(tc.violations), violations)
+			continue
+		}
+		for i, v := range tc.violations {
+			if !strings.Contains(violations[i], v) {
+				t.Errorf("%v: expected violation %q, got %q", tc.desc, v, violations[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	versionsproto "github.com/HailoOSS/discovery-service/proto/versions"
+)
+
+// Versions returns each running version of a service, newest first, with where its instances are
+// running and when it was first seen, so deploy tooling can tell when a rollout is complete
+func Versions(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*versionsproto.Request)
+
+	if request.GetServiceName() == "" {
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.versions.servicename", "Service name is required")
+	}
+
+	return &versionsproto.Response{
+		Versions: versionsToProto(registry.ServiceVersions(request.GetServiceName())),
+	}, nil
+}
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"github.com/HailoOSS/protobuf/proto"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	webhooksproto "github.com/HailoOSS/discovery-service/proto/webhooks"
+)
+
+// Webhooks returns all webhooks registered to receive serviceup/servicedown events
+func Webhooks(req *server.Request) (proto.Message, errors.Error) {
+	return &webhooksproto.Response{
+		Webhooks: webhooksToProto(registry.Webhooks()),
+	}, nil
+}
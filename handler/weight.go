@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/HailoOSS/protobuf/proto"
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/discovery-service/registry"
+	"github.com/HailoOSS/platform/errors"
+	"github.com/HailoOSS/platform/server"
+
+	weightproto "github.com/HailoOSS/discovery-service/proto/weight"
+)
+
+// Weight adjusts the share of traffic an instance should receive, eg: to ramp up a canary
+func Weight(req *server.Request) (proto.Message, errors.Error) {
+	request := req.Data().(*weightproto.Request)
+	instanceId := request.GetInstanceId()
+
+	switch err := registry.SetWeight(instanceId, request.GetWeight()); err {
+	case nil:
+	case registry.ErrInvalidWeight:
+		return nil, errors.BadRequest("com.HailoOSS.kernel.discovery.weight.invalid", err.Error())
+	case registry.ErrInstanceNotFound:
+		return nil, errors.NotFound("com.HailoOSS.kernel.discovery.weight.notfound", fmt.Sprintf("%v: %v", err, instanceId))
+	default:
+		log.Warnf("[Discovery] Error setting weight of %v: %v", instanceId, err)
+		return nil, errors.InternalServerError("com.HailoOSS.kernel.discovery.weight", fmt.Sprintf("Error setting weight: %v", err))
+	}
+
+	return &weightproto.Response{}, nil
+}
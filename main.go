@@ -8,11 +8,29 @@ import (
 	"github.com/HailoOSS/platform/server"
 	"github.com/HailoOSS/service/zookeeper"
 
+	createruleproto "github.com/HailoOSS/discovery-service/proto/createrule"
+	createwebhookproto "github.com/HailoOSS/discovery-service/proto/createwebhook"
+	deleteruleproto "github.com/HailoOSS/discovery-service/proto/deleterule"
+	deletewebhookproto "github.com/HailoOSS/discovery-service/proto/deletewebhook"
+	drainproto "github.com/HailoOSS/discovery-service/proto/drain"
 	endpointsproto "github.com/HailoOSS/discovery-service/proto/endpoints"
 	instancesproto "github.com/HailoOSS/discovery-service/proto/instances"
+	labelsproto "github.com/HailoOSS/discovery-service/proto/labels"
+	leaderproto "github.com/HailoOSS/discovery-service/proto/leader"
+	ownersproto "github.com/HailoOSS/discovery-service/proto/owners"
 	registerproto "github.com/HailoOSS/discovery-service/proto/register"
+	replayproto "github.com/HailoOSS/discovery-service/proto/replay"
+	resolveproto "github.com/HailoOSS/discovery-service/proto/resolve"
+	rulesproto "github.com/HailoOSS/discovery-service/proto/rules"
+	selectproto "github.com/HailoOSS/discovery-service/proto/select"
 	servicesproto "github.com/HailoOSS/discovery-service/proto/services"
+	subscribersproto "github.com/HailoOSS/discovery-service/proto/subscribers"
+	summaryproto "github.com/HailoOSS/discovery-service/proto/summary"
 	unregisterproto "github.com/HailoOSS/discovery-service/proto/unregister"
+	updateruleproto "github.com/HailoOSS/discovery-service/proto/updaterule"
+	versionsproto "github.com/HailoOSS/discovery-service/proto/versions"
+	webhooksproto "github.com/HailoOSS/discovery-service/proto/webhooks"
+	weightproto "github.com/HailoOSS/discovery-service/proto/weight"
 )
 
 func main() {
@@ -31,7 +49,7 @@ func main() {
 			Mean:             50,
 			Upper95:          100,
 			Handler:          handler.MultiRegister,
-			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			Authoriser:       handler.InstanceEndpointAuthoriser("com.HailoOSS.kernel.discovery.multiregister"),
 			RequestProtocol:  new(registerproto.MultiRequest),
 			ResponseProtocol: new(registerproto.Response),
 		},
@@ -40,7 +58,7 @@ func main() {
 			Mean:             50,
 			Upper95:          100,
 			Handler:          handler.Unregister,
-			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			Authoriser:       handler.InstanceEndpointAuthoriser("com.HailoOSS.kernel.discovery.unregister"),
 			RequestProtocol:  new(unregisterproto.Request),
 			ResponseProtocol: new(unregisterproto.Response),
 		},
@@ -70,10 +88,173 @@ func main() {
 			Authoriser:       server.OpenToTheWorldAuthoriser(),
 			RequestProtocol:  new(instancesproto.Request),
 			ResponseProtocol: new(instancesproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "select",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.Select,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(selectproto.Request),
+			ResponseProtocol: new(selectproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "leader",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Leader,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(leaderproto.Request),
+			ResponseProtocol: new(leaderproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "weight",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.Weight,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(weightproto.Request),
+			ResponseProtocol: new(weightproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "drain",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.Drain,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(drainproto.Request),
+			ResponseProtocol: new(drainproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "labels",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.Labels,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(labelsproto.Request),
+			ResponseProtocol: new(labelsproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "rules",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Rules,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(rulesproto.Request),
+			ResponseProtocol: new(rulesproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "createrule",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.CreateRule,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(createruleproto.Request),
+			ResponseProtocol: new(createruleproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "updaterule",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.UpdateRule,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(updateruleproto.Request),
+			ResponseProtocol: new(updateruleproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "deleterule",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.DeleteRule,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(deleteruleproto.Request),
+			ResponseProtocol: new(deleteruleproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "resolve",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Resolve,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(resolveproto.Request),
+			ResponseProtocol: new(resolveproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "replay",
+			Mean:             200,
+			Upper95:          1000,
+			Handler:          handler.Replay,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(replayproto.Request),
+			ResponseProtocol: new(replayproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "webhooks",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Webhooks,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(webhooksproto.Request),
+			ResponseProtocol: new(webhooksproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "createwebhook",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.CreateWebhook,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(createwebhookproto.Request),
+			ResponseProtocol: new(createwebhookproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "deletewebhook",
+			Mean:             100,
+			Upper95:          500,
+			Handler:          handler.DeleteWebhook,
+			Authoriser:       server.RoleAuthoriser([]string{"ADMIN"}),
+			RequestProtocol:  new(deletewebhookproto.Request),
+			ResponseProtocol: new(deletewebhookproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "summary",
+			Mean:             50,
+			Upper95:          200,
+			Handler:          handler.Summary,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(summaryproto.Request),
+			ResponseProtocol: new(summaryproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "versions",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Versions,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(versionsproto.Request),
+			ResponseProtocol: new(versionsproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "subscribers",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Subscribers,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(subscribersproto.Request),
+			ResponseProtocol: new(subscribersproto.Response),
+		},
+		&server.Endpoint{
+			Name:             "owners",
+			Mean:             50,
+			Upper95:          100,
+			Handler:          handler.Owners,
+			Authoriser:       server.OpenToTheWorldAuthoriser(),
+			RequestProtocol:  new(ownersproto.Request),
+			ResponseProtocol: new(ownersproto.Response),
 		})
 
 	registry.Init()
 	server.HealthCheck(zookeeper.HealthCheckId, zookeeper.HealthCheck())
+	server.HealthCheck(registry.ReadyHealthCheckId, registry.ReadyHealthCheck())
 	zookeeper.WaitForConnect(time.Second)
 	server.BindAndRun()
 }
@@ -0,0 +1,60 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/createrule/createrule.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_createrule is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/createrule/createrule.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_createrule
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+import com_HailoOSS_kernel_discovery "github.com/HailoOSS/discovery-service/proto"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Rule             *com_HailoOSS_kernel_discovery.Rule `protobuf:"bytes,1,req,name=rule" json:"rule,omitempty"`
+	XXX_unrecognized []byte                              `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetRule() *com_HailoOSS_kernel_discovery.Rule {
+	if m != nil {
+		return m.Rule
+	}
+	return nil
+}
+
+type Response struct {
+	Rule             *com_HailoOSS_kernel_discovery.Rule `protobuf:"bytes,1,req,name=rule" json:"rule,omitempty"`
+	XXX_unrecognized []byte                              `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetRule() *com_HailoOSS_kernel_discovery.Rule {
+	if m != nil {
+		return m.Rule
+	}
+	return nil
+}
+
+func init() {
+}
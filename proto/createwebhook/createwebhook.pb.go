@@ -0,0 +1,60 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/createwebhook/createwebhook.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_createwebhook is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/createwebhook/createwebhook.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_createwebhook
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+import com_HailoOSS_kernel_discovery "github.com/HailoOSS/discovery-service/proto"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Webhook          *com_HailoOSS_kernel_discovery.Webhook `protobuf:"bytes,1,req,name=webhook" json:"webhook,omitempty"`
+	XXX_unrecognized []byte                                 `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetWebhook() *com_HailoOSS_kernel_discovery.Webhook {
+	if m != nil {
+		return m.Webhook
+	}
+	return nil
+}
+
+type Response struct {
+	Webhook          *com_HailoOSS_kernel_discovery.Webhook `protobuf:"bytes,1,req,name=webhook" json:"webhook,omitempty"`
+	XXX_unrecognized []byte                                 `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetWebhook() *com_HailoOSS_kernel_discovery.Webhook {
+	if m != nil {
+		return m.Webhook
+	}
+	return nil
+}
+
+func init() {
+}
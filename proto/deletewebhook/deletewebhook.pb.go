@@ -0,0 +1,51 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/deletewebhook/deletewebhook.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_deletewebhook is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/deletewebhook/deletewebhook.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_deletewebhook
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Id               *string `protobuf:"bytes,1,req,name=id" json:"id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetId() string {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return ""
+}
+
+type Response struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func init() {
+}
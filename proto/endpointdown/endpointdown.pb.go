@@ -0,0 +1,91 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/endpointdown/endpointdown.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_endpointdown is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/endpointdown/endpointdown.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_endpointdown
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	ServiceName      *string `protobuf:"bytes,1,req,name=serviceName" json:"serviceName,omitempty"`
+	ServiceVersion   *uint64 `protobuf:"varint,2,req,name=serviceVersion" json:"serviceVersion,omitempty"`
+	EndpointName     *string `protobuf:"bytes,3,req,name=endpointName" json:"endpointName,omitempty"`
+	Subscribe        *string `protobuf:"bytes,4,opt,name=subscribe" json:"subscribe,omitempty"`
+	Mean             *uint32 `protobuf:"varint,5,opt,name=mean" json:"mean,omitempty"`
+	Upper95          *uint32 `protobuf:"varint,6,opt,name=upper95" json:"upper95,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetServiceName() string {
+	if m != nil && m.ServiceName != nil {
+		return *m.ServiceName
+	}
+	return ""
+}
+
+func (m *Request) GetServiceVersion() uint64 {
+	if m != nil && m.ServiceVersion != nil {
+		return *m.ServiceVersion
+	}
+	return 0
+}
+
+func (m *Request) GetEndpointName() string {
+	if m != nil && m.EndpointName != nil {
+		return *m.EndpointName
+	}
+	return ""
+}
+
+func (m *Request) GetSubscribe() string {
+	if m != nil && m.Subscribe != nil {
+		return *m.Subscribe
+	}
+	return ""
+}
+
+func (m *Request) GetMean() uint32 {
+	if m != nil && m.Mean != nil {
+		return *m.Mean
+	}
+	return 0
+}
+
+func (m *Request) GetUpper95() uint32 {
+	if m != nil && m.Upper95 != nil {
+		return *m.Upper95
+	}
+	return 0
+}
+
+type Response struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func init() {
+}
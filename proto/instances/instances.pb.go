@@ -18,6 +18,7 @@ package com_HailoOSS_kernel_discovery_instances
 import proto "github.com/HailoOSS/protobuf/proto"
 import json "encoding/json"
 import math "math"
+import com_HailoOSS_kernel_discovery "github.com/HailoOSS/discovery-service/proto"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -27,6 +28,7 @@ var _ = math.Inf
 type Request struct {
 	AzName           *string `protobuf:"bytes,1,opt,name=azName" json:"azName,omitempty"`
 	ServiceName      *string `protobuf:"bytes,2,opt,name=serviceName" json:"serviceName,omitempty"`
+	Region           *string `protobuf:"bytes,3,opt,name=region" json:"region,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -48,8 +50,16 @@ func (m *Request) GetServiceName() string {
 	return ""
 }
 
+func (m *Request) GetRegion() string {
+	if m != nil && m.Region != nil {
+		return *m.Region
+	}
+	return ""
+}
+
 type Response struct {
 	Instances        []*Instance `protobuf:"bytes,1,rep,name=instances" json:"instances,omitempty"`
+	Stale            *bool       `protobuf:"varint,2,opt,name=stale" json:"stale,omitempty"`
 	XXX_unrecognized []byte      `json:"-"`
 }
 
@@ -64,16 +74,26 @@ func (m *Response) GetInstances() []*Instance {
 	return nil
 }
 
+func (m *Response) GetStale() bool {
+	if m != nil && m.Stale != nil {
+		return *m.Stale
+	}
+	return false
+}
+
 type Instance struct {
-	InstanceId         *string  `protobuf:"bytes,1,req,name=instanceId" json:"instanceId,omitempty"`
-	Hostname           *string  `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
-	ServiceName        *string  `protobuf:"bytes,3,req,name=serviceName" json:"serviceName,omitempty"`
-	ServiceDescription *string  `protobuf:"bytes,4,opt,name=serviceDescription" json:"serviceDescription,omitempty"`
-	ServiceVersion     *uint64  `protobuf:"varint,5,req,name=serviceVersion" json:"serviceVersion,omitempty"`
-	AzName             *string  `protobuf:"bytes,6,req,name=azName" json:"azName,omitempty"`
-	SubTopic           []string `protobuf:"bytes,7,rep,name=subTopic" json:"subTopic,omitempty"`
-	MachineClass       *string  `protobuf:"bytes,8,opt,name=machineClass" json:"machineClass,omitempty"`
-	XXX_unrecognized   []byte   `json:"-"`
+	InstanceId         *string                                `protobuf:"bytes,1,req,name=instanceId" json:"instanceId,omitempty"`
+	Hostname           *string                                `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
+	ServiceName        *string                                `protobuf:"bytes,3,req,name=serviceName" json:"serviceName,omitempty"`
+	ServiceDescription *string                                `protobuf:"bytes,4,opt,name=serviceDescription" json:"serviceDescription,omitempty"`
+	ServiceVersion     *uint64                                `protobuf:"varint,5,req,name=serviceVersion" json:"serviceVersion,omitempty"`
+	AzName             *string                                `protobuf:"bytes,6,req,name=azName" json:"azName,omitempty"`
+	SubTopic           []string                               `protobuf:"bytes,7,rep,name=subTopic" json:"subTopic,omitempty"`
+	MachineClass       *string                                `protobuf:"bytes,8,opt,name=machineClass" json:"machineClass,omitempty"`
+	Region             *string                                `protobuf:"bytes,9,opt,name=region" json:"region,omitempty"`
+	Weight             *uint32                                `protobuf:"varint,10,opt,name=weight" json:"weight,omitempty"`
+	Labels             []*com_HailoOSS_kernel_discovery.Label `protobuf:"bytes,11,rep,name=labels" json:"labels,omitempty"`
+	XXX_unrecognized   []byte                                 `json:"-"`
 }
 
 func (m *Instance) Reset()         { *m = Instance{} }
@@ -136,5 +156,26 @@ func (m *Instance) GetMachineClass() string {
 	return ""
 }
 
+func (m *Instance) GetRegion() string {
+	if m != nil && m.Region != nil {
+		return *m.Region
+	}
+	return ""
+}
+
+func (m *Instance) GetWeight() uint32 {
+	if m != nil && m.Weight != nil {
+		return *m.Weight
+	}
+	return 0
+}
+
+func (m *Instance) GetLabels() []*com_HailoOSS_kernel_discovery.Label {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
 func init() {
 }
@@ -0,0 +1,60 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/labels/labels.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_labels is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/labels/labels.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_labels
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+import com_HailoOSS_kernel_discovery "github.com/HailoOSS/discovery-service/proto"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	InstanceId       *string                                `protobuf:"bytes,1,req,name=instanceId" json:"instanceId,omitempty"`
+	Labels           []*com_HailoOSS_kernel_discovery.Label `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty"`
+	XXX_unrecognized []byte                                 `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetInstanceId() string {
+	if m != nil && m.InstanceId != nil {
+		return *m.InstanceId
+	}
+	return ""
+}
+
+func (m *Request) GetLabels() []*com_HailoOSS_kernel_discovery.Label {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+type Response struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func init() {
+}
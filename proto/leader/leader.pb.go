@@ -0,0 +1,99 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/leader/leader.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_leader is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/leader/leader.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_leader
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+type Response struct {
+	LeaderId         *string          `protobuf:"bytes,1,req,name=leaderId" json:"leaderId,omitempty"`
+	Hostname         *string          `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
+	Nodes            []*Response_Node `protobuf:"bytes,3,rep,name=nodes" json:"nodes,omitempty"`
+	XXX_unrecognized []byte           `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetLeaderId() string {
+	if m != nil && m.LeaderId != nil {
+		return *m.LeaderId
+	}
+	return ""
+}
+
+func (m *Response) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Response) GetNodes() []*Response_Node {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+type Response_Node struct {
+	Id               *string `protobuf:"bytes,1,req,name=id" json:"id,omitempty"`
+	Hostname         *string `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
+	Leader           *bool   `protobuf:"varint,3,req,name=leader" json:"leader,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Node) Reset()         { *m = Response_Node{} }
+func (m *Response_Node) String() string { return proto.CompactTextString(m) }
+func (*Response_Node) ProtoMessage()    {}
+
+func (m *Response_Node) GetId() string {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return ""
+}
+
+func (m *Response_Node) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Response_Node) GetLeader() bool {
+	if m != nil && m.Leader != nil {
+		return *m.Leader
+	}
+	return false
+}
+
+func init() {
+}
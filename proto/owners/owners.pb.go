@@ -0,0 +1,155 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/owners/owners.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_owners is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/owners/owners.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_owners
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Team             *string `protobuf:"bytes,1,opt,name=team" json:"team,omitempty"`
+	Email            *string `protobuf:"bytes,2,opt,name=email" json:"email,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetTeam() string {
+	if m != nil && m.Team != nil {
+		return *m.Team
+	}
+	return ""
+}
+
+func (m *Request) GetEmail() string {
+	if m != nil && m.Email != nil {
+		return *m.Email
+	}
+	return ""
+}
+
+type Response struct {
+	Teams            []*Response_Team `protobuf:"bytes,1,rep,name=teams" json:"teams,omitempty"`
+	XXX_unrecognized []byte           `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetTeams() []*Response_Team {
+	if m != nil {
+		return m.Teams
+	}
+	return nil
+}
+
+type Response_Service struct {
+	Name             *string  `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	Versions         []uint64 `protobuf:"varint,2,rep,name=versions" json:"versions,omitempty"`
+	Instances        *uint32  `protobuf:"varint,3,req,name=instances" json:"instances,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Response_Service) Reset()         { *m = Response_Service{} }
+func (m *Response_Service) String() string { return proto.CompactTextString(m) }
+func (*Response_Service) ProtoMessage()    {}
+
+func (m *Response_Service) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Response_Service) GetVersions() []uint64 {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+func (m *Response_Service) GetInstances() uint32 {
+	if m != nil && m.Instances != nil {
+		return *m.Instances
+	}
+	return 0
+}
+
+type Response_Contact struct {
+	Email            *string             `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
+	Mobile           *string             `protobuf:"bytes,2,opt,name=mobile" json:"mobile,omitempty"`
+	Services         []*Response_Service `protobuf:"bytes,3,rep,name=services" json:"services,omitempty"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+func (m *Response_Contact) Reset()         { *m = Response_Contact{} }
+func (m *Response_Contact) String() string { return proto.CompactTextString(m) }
+func (*Response_Contact) ProtoMessage()    {}
+
+func (m *Response_Contact) GetEmail() string {
+	if m != nil && m.Email != nil {
+		return *m.Email
+	}
+	return ""
+}
+
+func (m *Response_Contact) GetMobile() string {
+	if m != nil && m.Mobile != nil {
+		return *m.Mobile
+	}
+	return ""
+}
+
+func (m *Response_Contact) GetServices() []*Response_Service {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+type Response_Team struct {
+	Name             *string             `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Contacts         []*Response_Contact `protobuf:"bytes,2,rep,name=contacts" json:"contacts,omitempty"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+func (m *Response_Team) Reset()         { *m = Response_Team{} }
+func (m *Response_Team) String() string { return proto.CompactTextString(m) }
+func (*Response_Team) ProtoMessage()    {}
+
+func (m *Response_Team) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Response_Team) GetContacts() []*Response_Contact {
+	if m != nil {
+		return m.Contacts
+	}
+	return nil
+}
+
+func init() {
+}
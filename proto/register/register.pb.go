@@ -112,6 +112,9 @@ type MultiRequest struct {
 	Service          *com_HailoOSS_kernel_discovery.Service `protobuf:"bytes,4,req,name=service" json:"service,omitempty"`
 	Endpoints        []*MultiRequest_Endpoint               `protobuf:"bytes,5,rep,name=endpoints" json:"endpoints,omitempty"`
 	MachineClass     *string                                `protobuf:"bytes,6,opt,name=machineClass" json:"machineClass,omitempty"`
+	Weight           *uint32                                `protobuf:"varint,7,opt,name=weight" json:"weight,omitempty"`
+	Update           *bool                                  `protobuf:"varint,8,opt,name=update" json:"update,omitempty"`
+	Labels           []*com_HailoOSS_kernel_discovery.Label `protobuf:"bytes,9,rep,name=labels" json:"labels,omitempty"`
 	XXX_unrecognized []byte                                 `json:"-"`
 }
 
@@ -161,6 +164,27 @@ func (m *MultiRequest) GetMachineClass() string {
 	return ""
 }
 
+func (m *MultiRequest) GetWeight() uint32 {
+	if m != nil && m.Weight != nil {
+		return *m.Weight
+	}
+	return 0
+}
+
+func (m *MultiRequest) GetUpdate() bool {
+	if m != nil && m.Update != nil {
+		return *m.Update
+	}
+	return false
+}
+
+func (m *MultiRequest) GetLabels() []*com_HailoOSS_kernel_discovery.Label {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
 type MultiRequest_Endpoint struct {
 	Name             *string `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
 	Mean             *int32  `protobuf:"varint,2,req,name=mean" json:"mean,omitempty"`
@@ -0,0 +1,214 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/replay/replay.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_replay is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/replay/replay.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_replay
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Response_Event_Type int32
+
+const (
+	Response_Event_UP      Response_Event_Type = 1
+	Response_Event_DOWN    Response_Event_Type = 2
+	Response_Event_DRAINED Response_Event_Type = 3
+	Response_Event_SUSPECT Response_Event_Type = 4
+	Response_Event_UPDATED Response_Event_Type = 5
+)
+
+var Response_Event_Type_name = map[int32]string{
+	1: "UP",
+	2: "DOWN",
+	3: "DRAINED",
+	4: "SUSPECT",
+	5: "UPDATED",
+}
+var Response_Event_Type_value = map[string]int32{
+	"UP":      1,
+	"DOWN":    2,
+	"DRAINED": 3,
+	"SUSPECT": 4,
+	"UPDATED": 5,
+}
+
+func (x Response_Event_Type) Enum() *Response_Event_Type {
+	p := new(Response_Event_Type)
+	*p = x
+	return p
+}
+func (x Response_Event_Type) String() string {
+	return proto.EnumName(Response_Event_Type_name, int32(x))
+}
+func (x *Response_Event_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Response_Event_Type_value, data, "Response_Event_Type")
+	if err != nil {
+		return err
+	}
+	*x = Response_Event_Type(value)
+	return nil
+}
+
+type Request struct {
+	SinceSeq         *uint64 `protobuf:"varint,1,opt,name=sinceSeq" json:"sinceSeq,omitempty"`
+	SinceTimestamp   *int64  `protobuf:"varint,2,opt,name=sinceTimestamp" json:"sinceTimestamp,omitempty"`
+	Limit            *uint32 `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetSinceSeq() uint64 {
+	if m != nil && m.SinceSeq != nil {
+		return *m.SinceSeq
+	}
+	return 0
+}
+
+func (m *Request) GetSinceTimestamp() int64 {
+	if m != nil && m.SinceTimestamp != nil {
+		return *m.SinceTimestamp
+	}
+	return 0
+}
+
+func (m *Request) GetLimit() uint32 {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
+	}
+	return 0
+}
+
+type Response struct {
+	Events           []*Response_Event `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+	LastSeq          *uint64           `protobuf:"varint,2,req,name=lastSeq" json:"lastSeq,omitempty"`
+	NodeId           *string           `protobuf:"bytes,3,req,name=nodeId" json:"nodeId,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetEvents() []*Response_Event {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+func (m *Response) GetLastSeq() uint64 {
+	if m != nil && m.LastSeq != nil {
+		return *m.LastSeq
+	}
+	return 0
+}
+
+func (m *Response) GetNodeId() string {
+	if m != nil && m.NodeId != nil {
+		return *m.NodeId
+	}
+	return ""
+}
+
+type Response_Event struct {
+	Seq              *uint64              `protobuf:"varint,1,req,name=seq" json:"seq,omitempty"`
+	Timestamp        *int64               `protobuf:"varint,2,req,name=timestamp" json:"timestamp,omitempty"`
+	Type             *Response_Event_Type `protobuf:"varint,3,req,name=type,enum=com.HailoOSS.kernel.discovery.replay.Response_Event_Type" json:"type,omitempty"`
+	InstanceId       *string              `protobuf:"bytes,4,req,name=instanceId" json:"instanceId,omitempty"`
+	Hostname         *string              `protobuf:"bytes,5,req,name=hostname" json:"hostname,omitempty"`
+	ServiceName      *string              `protobuf:"bytes,6,req,name=serviceName" json:"serviceName,omitempty"`
+	ServiceVersion   *uint64              `protobuf:"varint,7,req,name=serviceVersion" json:"serviceVersion,omitempty"`
+	AzName           *string              `protobuf:"bytes,8,req,name=azName" json:"azName,omitempty"`
+	Reason           *string              `protobuf:"bytes,9,opt,name=reason" json:"reason,omitempty"`
+	XXX_unrecognized []byte               `json:"-"`
+}
+
+func (m *Response_Event) Reset()         { *m = Response_Event{} }
+func (m *Response_Event) String() string { return proto.CompactTextString(m) }
+func (*Response_Event) ProtoMessage()    {}
+
+func (m *Response_Event) GetSeq() uint64 {
+	if m != nil && m.Seq != nil {
+		return *m.Seq
+	}
+	return 0
+}
+
+func (m *Response_Event) GetTimestamp() int64 {
+	if m != nil && m.Timestamp != nil {
+		return *m.Timestamp
+	}
+	return 0
+}
+
+func (m *Response_Event) GetType() Response_Event_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Response_Event_UP
+}
+
+func (m *Response_Event) GetInstanceId() string {
+	if m != nil && m.InstanceId != nil {
+		return *m.InstanceId
+	}
+	return ""
+}
+
+func (m *Response_Event) GetHostname() string {
+	if m != nil && m.Hostname != nil {
+		return *m.Hostname
+	}
+	return ""
+}
+
+func (m *Response_Event) GetServiceName() string {
+	if m != nil && m.ServiceName != nil {
+		return *m.ServiceName
+	}
+	return ""
+}
+
+func (m *Response_Event) GetServiceVersion() uint64 {
+	if m != nil && m.ServiceVersion != nil {
+		return *m.ServiceVersion
+	}
+	return 0
+}
+
+func (m *Response_Event) GetAzName() string {
+	if m != nil && m.AzName != nil {
+		return *m.AzName
+	}
+	return ""
+}
+
+func (m *Response_Event) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterEnum("com.HailoOSS.kernel.discovery.replay.Response_Event_Type", Response_Event_Type_name, Response_Event_Type_value)
+}
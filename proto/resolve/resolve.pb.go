@@ -0,0 +1,83 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/resolve/resolve.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_resolve is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/resolve/resolve.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_resolve
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Service          *string `protobuf:"bytes,1,req,name=service" json:"service,omitempty"`
+	Caller           *string `protobuf:"bytes,2,opt,name=caller" json:"caller,omitempty"`
+	CallerId         *string `protobuf:"bytes,3,opt,name=callerId" json:"callerId,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Request) GetCaller() string {
+	if m != nil && m.Caller != nil {
+		return *m.Caller
+	}
+	return ""
+}
+
+func (m *Request) GetCallerId() string {
+	if m != nil && m.CallerId != nil {
+		return *m.CallerId
+	}
+	return ""
+}
+
+type Response struct {
+	Versions         []uint64 `protobuf:"varint,1,rep,name=versions" json:"versions,omitempty"`
+	RuleId           *string  `protobuf:"bytes,2,opt,name=ruleId" json:"ruleId,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetVersions() []uint64 {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+func (m *Response) GetRuleId() string {
+	if m != nil && m.RuleId != nil {
+		return *m.RuleId
+	}
+	return ""
+}
+
+func init() {
+}
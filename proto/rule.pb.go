@@ -0,0 +1,74 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/rule.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/rule.proto
+
+It has these top-level messages:
+	Rule
+*/
+package com_HailoOSS_kernel_discovery
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Rule struct {
+	Id               *string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Service          *string `protobuf:"bytes,2,req,name=service" json:"service,omitempty"`
+	Version          *uint64 `protobuf:"varint,3,req,name=version" json:"version,omitempty"`
+	Percentage       *uint32 `protobuf:"varint,4,req,name=percentage" json:"percentage,omitempty"`
+	CallerPrefix     *string `protobuf:"bytes,5,opt,name=callerPrefix" json:"callerPrefix,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Rule) Reset()         { *m = Rule{} }
+func (m *Rule) String() string { return proto.CompactTextString(m) }
+func (*Rule) ProtoMessage()    {}
+
+func (m *Rule) GetId() string {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return ""
+}
+
+func (m *Rule) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Rule) GetVersion() uint64 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *Rule) GetPercentage() uint32 {
+	if m != nil && m.Percentage != nil {
+		return *m.Percentage
+	}
+	return 0
+}
+
+func (m *Rule) GetCallerPrefix() string {
+	if m != nil && m.CallerPrefix != nil {
+		return *m.CallerPrefix
+	}
+	return ""
+}
+
+func init() {
+}
@@ -0,0 +1,76 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/select/select.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_select is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/select/select.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_select
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+import com_HailoOSS_kernel_discovery_instances "github.com/HailoOSS/discovery-service/proto/instances"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	ServiceName      *string `protobuf:"bytes,1,req,name=serviceName" json:"serviceName,omitempty"`
+	AzName           *string `protobuf:"bytes,2,opt,name=azName" json:"azName,omitempty"`
+	Limit            *uint32 `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetServiceName() string {
+	if m != nil && m.ServiceName != nil {
+		return *m.ServiceName
+	}
+	return ""
+}
+
+func (m *Request) GetAzName() string {
+	if m != nil && m.AzName != nil {
+		return *m.AzName
+	}
+	return ""
+}
+
+func (m *Request) GetLimit() uint32 {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
+	}
+	return 0
+}
+
+type Response struct {
+	Instances        []*com_HailoOSS_kernel_discovery_instances.Instance `protobuf:"bytes,1,rep,name=instances" json:"instances,omitempty"`
+	XXX_unrecognized []byte                                              `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetInstances() []*com_HailoOSS_kernel_discovery_instances.Instance {
+	if m != nil {
+		return m.Instances
+	}
+	return nil
+}
+
+func init() {
+}
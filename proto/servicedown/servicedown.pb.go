@@ -23,15 +23,62 @@ var _ = proto.Marshal
 var _ = &json.SyntaxError{}
 var _ = math.Inf
 
+type Request_Reason int32
+
+const (
+	Request_UNKNOWN           Request_Reason = 0
+	Request_UNREGISTER        Request_Reason = 1
+	Request_HEARTBEAT_TIMEOUT Request_Reason = 2
+	Request_SESSION_EXPIRY    Request_Reason = 3
+	Request_DRAINED           Request_Reason = 4
+	Request_ADMIN_EVICTION    Request_Reason = 5
+)
+
+var Request_Reason_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "UNREGISTER",
+	2: "HEARTBEAT_TIMEOUT",
+	3: "SESSION_EXPIRY",
+	4: "DRAINED",
+	5: "ADMIN_EVICTION",
+}
+var Request_Reason_value = map[string]int32{
+	"UNKNOWN":           0,
+	"UNREGISTER":        1,
+	"HEARTBEAT_TIMEOUT": 2,
+	"SESSION_EXPIRY":    3,
+	"DRAINED":           4,
+	"ADMIN_EVICTION":    5,
+}
+
+func (x Request_Reason) Enum() *Request_Reason {
+	p := new(Request_Reason)
+	*p = x
+	return p
+}
+func (x Request_Reason) String() string {
+	return proto.EnumName(Request_Reason_name, int32(x))
+}
+func (x *Request_Reason) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Request_Reason_value, data, "Request_Reason")
+	if err != nil {
+		return err
+	}
+	*x = Request_Reason(value)
+	return nil
+}
+
 type Request struct {
-	InstanceId         *string `protobuf:"bytes,1,req,name=instanceId" json:"instanceId,omitempty"`
-	Hostname           *string `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
-	ServiceName        *string `protobuf:"bytes,3,req,name=serviceName" json:"serviceName,omitempty"`
-	ServiceDescription *string `protobuf:"bytes,4,opt,name=serviceDescription" json:"serviceDescription,omitempty"`
-	ServiceVersion     *uint64 `protobuf:"varint,5,req,name=serviceVersion" json:"serviceVersion,omitempty"`
-	EndpointName       *string `protobuf:"bytes,6,opt,name=endpointName" json:"endpointName,omitempty"`
-	AzName             *string `protobuf:"bytes,7,req,name=azName" json:"azName,omitempty"`
-	XXX_unrecognized   []byte  `json:"-"`
+	InstanceId         *string         `protobuf:"bytes,1,req,name=instanceId" json:"instanceId,omitempty"`
+	Hostname           *string         `protobuf:"bytes,2,req,name=hostname" json:"hostname,omitempty"`
+	ServiceName        *string         `protobuf:"bytes,3,req,name=serviceName" json:"serviceName,omitempty"`
+	ServiceDescription *string         `protobuf:"bytes,4,opt,name=serviceDescription" json:"serviceDescription,omitempty"`
+	ServiceVersion     *uint64         `protobuf:"varint,5,req,name=serviceVersion" json:"serviceVersion,omitempty"`
+	EndpointName       *string         `protobuf:"bytes,6,opt,name=endpointName" json:"endpointName,omitempty"`
+	AzName             *string         `protobuf:"bytes,7,req,name=azName" json:"azName,omitempty"`
+	Reason             *Request_Reason `protobuf:"varint,8,opt,name=reason,enum=com.HailoOSS.kernel.discovery.servicedown.Request_Reason" json:"reason,omitempty"`
+	LastHeartbeat      *int64          `protobuf:"varint,9,opt,name=lastHeartbeat" json:"lastHeartbeat,omitempty"`
+	XXX_unrecognized   []byte          `json:"-"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -87,6 +134,20 @@ func (m *Request) GetAzName() string {
 	return ""
 }
 
+func (m *Request) GetReason() Request_Reason {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return Request_UNKNOWN
+}
+
+func (m *Request) GetLastHeartbeat() int64 {
+	if m != nil && m.LastHeartbeat != nil {
+		return *m.LastHeartbeat
+	}
+	return 0
+}
+
 type Response struct {
 	XXX_unrecognized []byte `json:"-"`
 }
@@ -96,4 +157,5 @@ func (m *Response) String() string { return proto.CompactTextString(m) }
 func (*Response) ProtoMessage()    {}
 
 func init() {
+	proto.RegisterEnum("com.HailoOSS.kernel.discovery.servicedown.Request_Reason", Request_Reason_name, Request_Reason_value)
 }
@@ -26,6 +26,7 @@ var _ = math.Inf
 
 type Request struct {
 	Service          *string `protobuf:"bytes,1,opt,name=service" json:"service,omitempty"`
+	Region           *string `protobuf:"bytes,2,opt,name=region" json:"region,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -40,8 +41,16 @@ func (m *Request) GetService() string {
 	return ""
 }
 
+func (m *Request) GetRegion() string {
+	if m != nil && m.Region != nil {
+		return *m.Region
+	}
+	return ""
+}
+
 type Response struct {
 	Services         []*com_HailoOSS_kernel_discovery.Service `protobuf:"bytes,1,rep,name=services" json:"services,omitempty"`
+	Stale            *bool                                    `protobuf:"varint,2,opt,name=stale" json:"stale,omitempty"`
 	XXX_unrecognized []byte                                   `json:"-"`
 }
 
@@ -56,5 +65,12 @@ func (m *Response) GetServices() []*com_HailoOSS_kernel_discovery.Service {
 	return nil
 }
 
+func (m *Response) GetStale() bool {
+	if m != nil && m.Stale != nil {
+		return *m.Stale
+	}
+	return false
+}
+
 func init() {
 }
@@ -0,0 +1,115 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/subscribers/subscribers.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_subscribers is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/subscribers/subscribers.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_subscribers
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	Topic            *string `protobuf:"bytes,1,req,name=topic" json:"topic,omitempty"`
+	Prefix           *bool   `protobuf:"varint,2,opt,name=prefix" json:"prefix,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
+	}
+	return ""
+}
+
+func (m *Request) GetPrefix() bool {
+	if m != nil && m.Prefix != nil {
+		return *m.Prefix
+	}
+	return false
+}
+
+type Response struct {
+	Subscribers      []*Response_Subscriber `protobuf:"bytes,1,rep,name=subscribers" json:"subscribers,omitempty"`
+	XXX_unrecognized []byte                 `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetSubscribers() []*Response_Subscriber {
+	if m != nil {
+		return m.Subscribers
+	}
+	return nil
+}
+
+type Response_Subscriber struct {
+	Topic            *string  `protobuf:"bytes,1,req,name=topic" json:"topic,omitempty"`
+	ServiceName      *string  `protobuf:"bytes,2,req,name=serviceName" json:"serviceName,omitempty"`
+	ServiceVersion   *uint64  `protobuf:"varint,3,req,name=serviceVersion" json:"serviceVersion,omitempty"`
+	EndpointName     *string  `protobuf:"bytes,4,req,name=endpointName" json:"endpointName,omitempty"`
+	InstanceIds      []string `protobuf:"bytes,5,rep,name=instanceIds" json:"instanceIds,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Response_Subscriber) Reset()         { *m = Response_Subscriber{} }
+func (m *Response_Subscriber) String() string { return proto.CompactTextString(m) }
+func (*Response_Subscriber) ProtoMessage()    {}
+
+func (m *Response_Subscriber) GetTopic() string {
+	if m != nil && m.Topic != nil {
+		return *m.Topic
+	}
+	return ""
+}
+
+func (m *Response_Subscriber) GetServiceName() string {
+	if m != nil && m.ServiceName != nil {
+		return *m.ServiceName
+	}
+	return ""
+}
+
+func (m *Response_Subscriber) GetServiceVersion() uint64 {
+	if m != nil && m.ServiceVersion != nil {
+		return *m.ServiceVersion
+	}
+	return 0
+}
+
+func (m *Response_Subscriber) GetEndpointName() string {
+	if m != nil && m.EndpointName != nil {
+		return *m.EndpointName
+	}
+	return ""
+}
+
+func (m *Response_Subscriber) GetInstanceIds() []string {
+	if m != nil {
+		return m.InstanceIds
+	}
+	return nil
+}
+
+func init() {
+}
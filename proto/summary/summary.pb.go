@@ -0,0 +1,179 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/summary/summary.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_summary is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/summary/summary.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_summary
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+type Response struct {
+	Revision         *uint64                  `protobuf:"varint,1,req,name=revision" json:"revision,omitempty"`
+	Instances        *uint32                  `protobuf:"varint,2,req,name=instances" json:"instances,omitempty"`
+	Hosts            *uint32                  `protobuf:"varint,3,req,name=hosts" json:"hosts,omitempty"`
+	Nodes            *uint32                  `protobuf:"varint,4,req,name=nodes" json:"nodes,omitempty"`
+	Services         []*Response_Count        `protobuf:"bytes,5,rep,name=services" json:"services,omitempty"`
+	Versions         []*Response_VersionCount `protobuf:"bytes,6,rep,name=versions" json:"versions,omitempty"`
+	AzNames          []*Response_Count        `protobuf:"bytes,7,rep,name=azNames" json:"azNames,omitempty"`
+	MachineClasses   []*Response_Count        `protobuf:"bytes,8,rep,name=machineClasses" json:"machineClasses,omitempty"`
+	Stale            *bool                    `protobuf:"varint,9,opt,name=stale" json:"stale,omitempty"`
+	NodesUnknown     *bool                    `protobuf:"varint,10,opt,name=nodesUnknown" json:"nodesUnknown,omitempty"`
+	XXX_unrecognized []byte                   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetRevision() uint64 {
+	if m != nil && m.Revision != nil {
+		return *m.Revision
+	}
+	return 0
+}
+
+func (m *Response) GetInstances() uint32 {
+	if m != nil && m.Instances != nil {
+		return *m.Instances
+	}
+	return 0
+}
+
+func (m *Response) GetHosts() uint32 {
+	if m != nil && m.Hosts != nil {
+		return *m.Hosts
+	}
+	return 0
+}
+
+func (m *Response) GetNodes() uint32 {
+	if m != nil && m.Nodes != nil {
+		return *m.Nodes
+	}
+	return 0
+}
+
+func (m *Response) GetServices() []*Response_Count {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+func (m *Response) GetVersions() []*Response_VersionCount {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+func (m *Response) GetAzNames() []*Response_Count {
+	if m != nil {
+		return m.AzNames
+	}
+	return nil
+}
+
+func (m *Response) GetMachineClasses() []*Response_Count {
+	if m != nil {
+		return m.MachineClasses
+	}
+	return nil
+}
+
+func (m *Response) GetStale() bool {
+	if m != nil && m.Stale != nil {
+		return *m.Stale
+	}
+	return false
+}
+
+func (m *Response) GetNodesUnknown() bool {
+	if m != nil && m.NodesUnknown != nil {
+		return *m.NodesUnknown
+	}
+	return false
+}
+
+type Response_Count struct {
+	Name             *string `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	Count            *uint32 `protobuf:"varint,2,req,name=count" json:"count,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_Count) Reset()         { *m = Response_Count{} }
+func (m *Response_Count) String() string { return proto.CompactTextString(m) }
+func (*Response_Count) ProtoMessage()    {}
+
+func (m *Response_Count) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Response_Count) GetCount() uint32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return 0
+}
+
+type Response_VersionCount struct {
+	Service          *string `protobuf:"bytes,1,req,name=service" json:"service,omitempty"`
+	Version          *uint64 `protobuf:"varint,2,req,name=version" json:"version,omitempty"`
+	Count            *uint32 `protobuf:"varint,3,req,name=count" json:"count,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_VersionCount) Reset()         { *m = Response_VersionCount{} }
+func (m *Response_VersionCount) String() string { return proto.CompactTextString(m) }
+func (*Response_VersionCount) ProtoMessage()    {}
+
+func (m *Response_VersionCount) GetService() string {
+	if m != nil && m.Service != nil {
+		return *m.Service
+	}
+	return ""
+}
+
+func (m *Response_VersionCount) GetVersion() uint64 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *Response_VersionCount) GetCount() uint32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return 0
+}
+
+func init() {
+}
@@ -0,0 +1,131 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/versions/versions.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_versions is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/versions/versions.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_versions
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	ServiceName      *string `protobuf:"bytes,1,req,name=serviceName" json:"serviceName,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+func (m *Request) GetServiceName() string {
+	if m != nil && m.ServiceName != nil {
+		return *m.ServiceName
+	}
+	return ""
+}
+
+type Response struct {
+	Versions         []*Response_Version `protobuf:"bytes,1,rep,name=versions" json:"versions,omitempty"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetVersions() []*Response_Version {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+type Response_AzCount struct {
+	AzName           *string `protobuf:"bytes,1,req,name=azName" json:"azName,omitempty"`
+	Count            *uint32 `protobuf:"varint,2,req,name=count" json:"count,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Response_AzCount) Reset()         { *m = Response_AzCount{} }
+func (m *Response_AzCount) String() string { return proto.CompactTextString(m) }
+func (*Response_AzCount) ProtoMessage()    {}
+
+func (m *Response_AzCount) GetAzName() string {
+	if m != nil && m.AzName != nil {
+		return *m.AzName
+	}
+	return ""
+}
+
+func (m *Response_AzCount) GetCount() uint32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return 0
+}
+
+type Response_Version struct {
+	Version          *uint64             `protobuf:"varint,1,req,name=version" json:"version,omitempty"`
+	Instances        *uint32             `protobuf:"varint,2,req,name=instances" json:"instances,omitempty"`
+	AzNames          []*Response_AzCount `protobuf:"bytes,3,rep,name=azNames" json:"azNames,omitempty"`
+	FirstSeen        *int64              `protobuf:"varint,4,req,name=firstSeen" json:"firstSeen,omitempty"`
+	Newest           *bool               `protobuf:"varint,5,req,name=newest" json:"newest,omitempty"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+func (m *Response_Version) Reset()         { *m = Response_Version{} }
+func (m *Response_Version) String() string { return proto.CompactTextString(m) }
+func (*Response_Version) ProtoMessage()    {}
+
+func (m *Response_Version) GetVersion() uint64 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *Response_Version) GetInstances() uint32 {
+	if m != nil && m.Instances != nil {
+		return *m.Instances
+	}
+	return 0
+}
+
+func (m *Response_Version) GetAzNames() []*Response_AzCount {
+	if m != nil {
+		return m.AzNames
+	}
+	return nil
+}
+
+func (m *Response_Version) GetFirstSeen() int64 {
+	if m != nil && m.FirstSeen != nil {
+		return *m.FirstSeen
+	}
+	return 0
+}
+
+func (m *Response_Version) GetNewest() bool {
+	if m != nil && m.Newest != nil {
+		return *m.Newest
+	}
+	return false
+}
+
+func init() {
+}
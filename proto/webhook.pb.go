@@ -0,0 +1,66 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/webhook.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/webhook.proto
+
+It has these top-level messages:
+	Webhook
+*/
+package com_HailoOSS_kernel_discovery
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Webhook struct {
+	Id               *string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Url              *string `protobuf:"bytes,2,req,name=url" json:"url,omitempty"`
+	Secret           *string `protobuf:"bytes,3,opt,name=secret" json:"secret,omitempty"`
+	ServicePrefix    *string `protobuf:"bytes,4,opt,name=servicePrefix" json:"servicePrefix,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Webhook) Reset()         { *m = Webhook{} }
+func (m *Webhook) String() string { return proto.CompactTextString(m) }
+func (*Webhook) ProtoMessage()    {}
+
+func (m *Webhook) GetId() string {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return ""
+}
+
+func (m *Webhook) GetUrl() string {
+	if m != nil && m.Url != nil {
+		return *m.Url
+	}
+	return ""
+}
+
+func (m *Webhook) GetSecret() string {
+	if m != nil && m.Secret != nil {
+		return *m.Secret
+	}
+	return ""
+}
+
+func (m *Webhook) GetServicePrefix() string {
+	if m != nil && m.ServicePrefix != nil {
+		return *m.ServicePrefix
+	}
+	return ""
+}
+
+func init() {
+}
@@ -0,0 +1,52 @@
+// Code generated by protoc-gen-go.
+// source: github.com/HailoOSS/discovery-service/proto/webhooks/webhooks.proto
+// DO NOT EDIT!
+
+/*
+Package com_HailoOSS_kernel_discovery_webhooks is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/HailoOSS/discovery-service/proto/webhooks/webhooks.proto
+
+It has these top-level messages:
+	Request
+	Response
+*/
+package com_HailoOSS_kernel_discovery_webhooks
+
+import proto "github.com/HailoOSS/protobuf/proto"
+import json "encoding/json"
+import math "math"
+import com_HailoOSS_kernel_discovery "github.com/HailoOSS/discovery-service/proto"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type Request struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+
+type Response struct {
+	Webhooks         []*com_HailoOSS_kernel_discovery.Webhook `protobuf:"bytes,1,rep,name=webhooks" json:"webhooks,omitempty"`
+	XXX_unrecognized []byte                                   `json:"-"`
+}
+
+func (m *Response) Reset()         { *m = Response{} }
+func (m *Response) String() string { return proto.CompactTextString(m) }
+func (*Response) ProtoMessage()    {}
+
+func (m *Response) GetWebhooks() []*com_HailoOSS_kernel_discovery.Webhook {
+	if m != nil {
+		return m.Webhooks
+	}
+	return nil
+}
+
+func init() {
+}
@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"strings"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	"github.com/HailoOSS/service/config"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const aclMigrateInterval = reapInterval
+
+// aclConfig configures who may read and modify our nodes in ZK, at hailo.service.discovery.acl. Without
+// credentials, nodes are world-writable, as they always have been.
+type aclConfig struct {
+	// Username and Password are the digest credentials we authenticate with, and which own our nodes
+	Username string
+	Password string
+	// DenyWorldRead stops anyone without credentials reading our nodes; this includes peer regions,
+	// unless they are configured with the same credentials
+	DenyWorldRead bool
+	// Extra grants access to other identities, eg: {"Scheme": "sasl", "Id": "ops@EXAMPLE.COM", "Perms": "r"}
+	Extra []struct {
+		Scheme string
+		Id     string
+		Perms  string
+	}
+	// Migrate has the leader secure existing world-writable nodes. Only enable it once every discovery
+	// node is configured with credentials, since any that aren't will no longer be able to register.
+	Migrate bool
+}
+
+// zkACLs holds the ACLs we create nodes with
+type zkACLs struct {
+	cfg *aclConfig
+	// public is for everything except private
+	public []gozk.ACL
+	// private is for nodes holding secrets (eg: webhooks), which are never world-readable once secured
+	private []gozk.ACL
+}
+
+var (
+	// acls defaults to world-writable nodes until Init has loaded configuration
+	acls = &zkACLs{
+		cfg:     &aclConfig{},
+		public:  gozk.WorldACL(gozk.PermAll),
+		private: gozk.WorldACL(gozk.PermAll),
+	}
+
+	// securedNodes are the trees secured when migrating
+	securedNodes = []string{rootNode, tombstoneNode, electionNode, presenceNode, publishedNode, routingNode, webhookNode, versionsNode}
+)
+
+// loadACLs reads ACL configuration, authenticating our ZK session if there are credentials
+func loadACLs() *zkACLs {
+	cfg := &aclConfig{}
+	if err := config.AtPath("hailo", "service", "discovery", "acl").AsStruct(cfg); err != nil {
+		log.Warnf("[Discovery] Failed to load ACL config, nodes will be world-writable: %v", err)
+	}
+	if cfg.Username == "" {
+		return &zkACLs{
+			cfg:     cfg,
+			public:  gozk.WorldACL(gozk.PermAll),
+			private: gozk.WorldACL(gozk.PermAll),
+		}
+	}
+
+	if err := zk.AddAuth("digest", cfg.credentials()); err != nil {
+		log.Errorf("[Discovery] Failed to authenticate with ZK as %v: %v", cfg.Username, err)
+	}
+
+	private := gozk.DigestACL(gozk.PermAll, cfg.Username, cfg.Password)
+	for _, e := range cfg.Extra {
+		private = append(private, gozk.ACL{Perms: parsePerms(e.Perms), Scheme: e.Scheme, ID: e.Id})
+	}
+	public := private
+	if !cfg.DenyWorldRead {
+		public = append(gozk.WorldACL(gozk.PermRead), private...)
+	}
+
+	log.Infof("[Discovery] Securing ZK nodes for %v (world readable: %v, %v others)", cfg.Username, !cfg.DenyWorldRead, len(cfg.Extra))
+	return &zkACLs{
+		cfg:     cfg,
+		public:  public,
+		private: private,
+	}
+}
+
+// credentials returns digest credentials in the form ZK expects
+func (cfg *aclConfig) credentials() []byte {
+	return []byte(cfg.Username + ":" + cfg.Password)
+}
+
+// authenticate adds our credentials, if any, to a connection to a peer region
+func (a *zkACLs) authenticate(conn *gozk.Conn) error {
+	if a.cfg.Username == "" {
+		return nil
+	}
+	return conn.AddAuth("digest", a.cfg.credentials())
+}
+
+// keepsSecrets returns whether private nodes are kept from the world; without credentials they can't be
+func (a *zkACLs) keepsSecrets() bool {
+	return a.cfg.Username != ""
+}
+
+// forPath returns the ACL to create a node with
+func (a *zkACLs) forPath(path string) []gozk.ACL {
+	if path == webhookNode || strings.HasPrefix(path, webhookNode+"/") {
+		return a.private
+	}
+	return a.public
+}
+
+// migrate secures any world-writable nodes left over from before we had credentials; run by the leader
+// until a full pass finds nothing left to secure, since once every node has credentials nothing new
+// is created world-writable
+func (a *zkACLs) migrate() error {
+	if a.cfg.Username == "" || !a.cfg.Migrate {
+		return errTaskDone
+	}
+	found := 0
+	for _, path := range securedNodes {
+		n, err := a.migrateTree(path)
+		if err != nil {
+			return err
+		}
+		found += n
+	}
+	if found == 0 {
+		log.Infof("[Discovery] No world-writable nodes left to secure")
+		return errTaskDone
+	}
+	return nil
+}
+
+// migrateTree secures a node and everything below it, returning how many world-writable nodes it found
+func (a *zkACLs) migrateTree(path string) (int, error) {
+	acl, stat, err := zk.GetACL(path)
+	if err == gozk.ErrNoNode {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	found := 0
+	if worldWritable(acl) {
+		found++
+		log.Infof("[Discovery] Securing world-writable node %v", path)
+		if _, err := zk.SetACL(path, a.forPath(path), stat.Aversion); err != nil && err != gozk.ErrNoNode && err != gozk.ErrBadVersion {
+			return found, err
+		}
+	}
+
+	children, _, err := zk.Children(path)
+	if err == gozk.ErrNoNode {
+		return found, nil
+	} else if err != nil {
+		return found, err
+	}
+	for _, child := range children {
+		n, err := a.migrateTree(path + "/" + child)
+		found += n
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// worldWritable returns whether an ACL lets anyone modify a node
+func worldWritable(acl []gozk.ACL) bool {
+	for _, a := range acl {
+		if a.Scheme == "world" && a.ID == "anyone" && a.Perms&^gozk.PermRead != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePerms parses permissions in ZK's usual "rwcda" form
+func parsePerms(s string) int32 {
+	var perms int32
+	for _, c := range strings.ToLower(s) {
+		switch c {
+		case 'r':
+			perms |= gozk.PermRead
+		case 'w':
+			perms |= gozk.PermWrite
+		case 'c':
+			perms |= gozk.PermCreate
+		case 'd':
+			perms |= gozk.PermDelete
+		case 'a':
+			perms |= gozk.PermAdmin
+		}
+	}
+	return perms
+}
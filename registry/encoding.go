@@ -0,0 +1,212 @@
+package registry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/service/config"
+	inst "github.com/HailoOSS/service/instrumentation"
+)
+
+// Instance documents are stored either as plain JSON, as they always have been, or behind a header
+// identifying their format, which can never be mistaken for JSON since that always starts with '{'.
+// We always read both, but only write the configured format, so every discovery node can learn to read
+// a new format before any start writing it.
+const (
+	encodingMagic = "\x00ds"
+
+	// FormatJSON is plain JSON, without a header
+	FormatJSON = "json"
+	// FormatGzip is gzipped JSON, roughly a quarter of the size for instances with many endpoints
+	FormatGzip = "gzip"
+
+	formatGzipHeader byte = 1
+
+	// documentSizeWarning is where we start worrying about ZK's 1MB limit on node size
+	documentSizeWarning = 512 * 1024
+)
+
+// Instance documents also carry a schema version, independent of their format. Documents written before
+// we had schema versions are version 1. Adding fields doesn't need a new version, since older discovery
+// nodes keep fields they don't understand when rewriting a document; changing the meaning of a field
+// does, along with an upgrade from the previous version.
+const (
+	instanceSchema uint32 = 2
+	schemaField           = "SchemaVersion"
+)
+
+var (
+	// instanceFormat is the format we write instance documents in
+	instanceFormat = FormatJSON
+
+	// schemaUpgrades bring a decoded document up to the next schema version, keyed on the version they
+	// upgrade from; version 2 only introduced the schema version itself, so there are none yet
+	schemaUpgrades = map[uint32]func(doc map[string]json.RawMessage) error{}
+
+	// instanceFields are the (lower cased) JSON fields of Instance, which we don't need to keep hold of
+	instanceFields = jsonFields(reflect.TypeOf(Instance{}))
+)
+
+func loadInstanceFormat() string {
+	format := config.AtPath("hailo", "service", "discovery", "encoding", "format").AsString(FormatJSON)
+	if format != FormatJSON && format != FormatGzip {
+		log.Warnf("[Discovery] Unknown instance document format %v, using %v", format, FormatJSON)
+		return FormatJSON
+	}
+	return format
+}
+
+// encodeInstance marshals an instance to be stored in ZK, in the configured format
+func encodeInstance(i *Instance) ([]byte, error) {
+	b, err := marshalInstance(i)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal instance JSON: %v", err)
+	}
+	inst.Gauge(1.0, "instance.size.json", len(b))
+
+	if instanceFormat == FormatGzip {
+		buf := bytes.NewBufferString(encodingMagic)
+		buf.WriteByte(formatGzipHeader)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, fmt.Errorf("Failed to compress instance: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("Failed to compress instance: %v", err)
+		}
+		b = buf.Bytes()
+	}
+
+	inst.Gauge(1.0, "instance.size.stored", len(b))
+	if len(b) > documentSizeWarning {
+		log.Warnf("[Discovery] Instance %v of %v is %v bytes when stored as %v", i.Id, i.Name, len(b), instanceFormat)
+	}
+	return b, nil
+}
+
+// decodeInstance unmarshals an instance stored in ZK, in any format we know
+func decodeInstance(b []byte) (*Instance, error) {
+	if bytes.HasPrefix(b, []byte(encodingMagic)) {
+		b = b[len(encodingMagic):]
+		if len(b) == 0 {
+			return nil, fmt.Errorf("Truncated instance document")
+		}
+
+		switch b[0] {
+		case formatGzipHeader:
+			r, err := gzip.NewReader(bytes.NewReader(b[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to decompress instance: %v", err)
+			}
+			if b, err = ioutil.ReadAll(r); err != nil {
+				return nil, fmt.Errorf("Failed to decompress instance: %v", err)
+			}
+		default:
+			return nil, fmt.Errorf("Unknown instance document format %v", b[0])
+		}
+	}
+
+	return unmarshalInstance(b)
+}
+
+// marshalInstance marshals an instance to JSON, along with its schema version and any fields we
+// didn't understand when reading it
+func marshalInstance(i *Instance) ([]byte, error) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	for k, v := range i.unknown {
+		if _, ok := doc[k]; !ok {
+			doc[k] = v
+		}
+	}
+
+	// a document from a newer node stays at its version, since we've kept what we don't understand
+	schema := instanceSchema
+	if i.schema > schema {
+		schema = i.schema
+	}
+	doc[schemaField] = json.RawMessage(strconv.FormatUint(uint64(schema), 10))
+
+	return json.Marshal(doc)
+}
+
+// unmarshalInstance unmarshals an instance from JSON of any schema version, upgrading older documents
+// and keeping hold of fields from newer ones that we don't understand
+func unmarshalInstance(b []byte) (*Instance, error) {
+	doc := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	schema := uint32(1)
+	if v, ok := doc[schemaField]; ok {
+		if err := json.Unmarshal(v, &schema); err != nil {
+			return nil, fmt.Errorf("Invalid instance schema version %s: %v", v, err)
+		}
+		delete(doc, schemaField)
+	}
+
+	upgraded := false
+	for v := schema; v < instanceSchema; v++ {
+		if upgrade, ok := schemaUpgrades[v]; ok {
+			if err := upgrade(doc); err != nil {
+				return nil, fmt.Errorf("Failed to upgrade instance from schema version %v: %v", v, err)
+			}
+			upgraded = true
+		}
+	}
+	if upgraded {
+		var err error
+		if b, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	i := &Instance{}
+	if err := json.Unmarshal(b, i); err != nil {
+		return nil, err
+	}
+	i.schema = schema
+	for k, v := range doc {
+		if !instanceFields[strings.ToLower(k)] {
+			if i.unknown == nil {
+				i.unknown = make(map[string]json.RawMessage)
+			}
+			i.unknown[k] = v
+		}
+	}
+	return i, nil
+}
+
+// jsonFields returns the lower cased names that the exported fields of a struct are marshalled as
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for n := 0; n < t.NumField(); n++ {
+		f := t.Field(n)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields[strings.ToLower(name)] = true
+	}
+	return fields
+}
@@ -0,0 +1,242 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// legacyDocument is an instance as stored before documents carried a schema version (or a weight)
+const legacyDocument = `{"Id":"instance-1","Hostname":"host-1","MachineClass":"default","Name":"com.HailoOSS.service.foo",` +
+	`"Description":"","AzName":"eu-west-1a","Source":"","OwnerEmail":"","OwnerMobile":"","OwnerTeam":"",` +
+	`"Version":20140101000000,"Endpoints":[{"Name":"bar","Subscribe":"","Sla":{"Mean":10,"Upper95":50}}]}`
+
+// storedSchema returns the schema version written into an encoded document
+func storedSchema(t *testing.T, b []byte) uint32 {
+	i, err := decodeInstance(b)
+	if err != nil {
+		t.Fatalf("Failed to decode instance: %v", err)
+	}
+	return i.schema
+}
+
+func TestDecodeLegacyDocument(t *testing.T) {
+	i, err := decodeInstance([]byte(legacyDocument))
+	if err != nil {
+		t.Fatalf("Failed to decode legacy instance: %v", err)
+	}
+	if i.Id != "instance-1" || i.Name != "com.HailoOSS.service.foo" || i.Version != 20140101000000 {
+		t.Errorf("Legacy instance decoded incorrectly: %+v", i)
+	}
+	if len(i.Endpoints) != 1 || i.Endpoints[0].Sla.Upper95 != 50 {
+		t.Errorf("Legacy endpoints decoded incorrectly: %+v", i.Endpoints)
+	}
+	if i.GetWeight() != DefaultWeight {
+		t.Errorf("Expected legacy instance to have weight %v, got %v", DefaultWeight, i.GetWeight())
+	}
+	if i.schema != 1 {
+		t.Errorf("Expected legacy instance to be schema 1, got %v", i.schema)
+	}
+	if len(i.unknown) != 0 {
+		t.Errorf("Expected no unknown fields in legacy instance, got %v", i.unknown)
+	}
+
+	b, err := encodeInstance(i)
+	if err != nil {
+		t.Fatalf("Failed to encode instance: %v", err)
+	}
+	if s := storedSchema(t, b); s != instanceSchema {
+		t.Errorf("Expected rewritten legacy instance to be schema %v, got %v", instanceSchema, s)
+	}
+}
+
+func TestRoundTripNewerDocument(t *testing.T) {
+	newer := instanceSchema + 1
+	doc := fmt.Sprintf(`{"SchemaVersion":%v,"Id":"instance-1","Name":"com.HailoOSS.service.foo","Weight":50,`+
+		`"Placement":{"rack":"r1"},"Endpoints":[]}`, newer)
+
+	i, err := decodeInstance([]byte(doc))
+	if err != nil {
+		t.Fatalf("Failed to decode newer instance: %v", err)
+	}
+	if i.Id != "instance-1" || i.GetWeight() != 50 {
+		t.Errorf("Newer instance decoded incorrectly: %+v", i)
+	}
+
+	// update it as we would a drain or weight change, which must keep what we don't understand
+	i.Weight = 25
+	b, err := encodeInstance(i)
+	if err != nil {
+		t.Fatalf("Failed to encode instance: %v", err)
+	}
+
+	stored := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatalf("Failed to unmarshal stored instance: %v", err)
+	}
+	if got := string(stored["Placement"]); got != `{"rack":"r1"}` {
+		t.Errorf("Expected unknown field to survive a rewrite, got %q", got)
+	}
+	if got := string(stored["Weight"]); got != "25" {
+		t.Errorf("Expected weight to be updated, got %q", got)
+	}
+	if got := string(stored[schemaField]); got != fmt.Sprint(newer) {
+		t.Errorf("Expected newer schema %v to be kept, got %q", newer, got)
+	}
+}
+
+func TestMergeRegistrationKeepsUnknown(t *testing.T) {
+	newer := instanceSchema + 1
+	doc := fmt.Sprintf(`{"SchemaVersion":%v,"Id":"instance-1","Name":"com.HailoOSS.service.foo","Version":1,`+
+		`"Weight":50,"Drained":true,"Labels":{"track":"canary"},"Zone":"rack-1","Endpoints":[]}`, newer)
+	stored, err := decodeInstance([]byte(doc))
+	if err != nil {
+		t.Fatalf("Failed to decode newer instance: %v", err)
+	}
+
+	// re-register with update, as an instance that knows nothing of the newer fields would
+	mergeRegistration(stored, &Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo", Version: 2})
+	b, err := encodeInstance(stored)
+	if err != nil {
+		t.Fatalf("Failed to encode instance: %v", err)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal stored instance: %v", err)
+	}
+	expected := map[string]string{
+		"Version":   "2",
+		"Weight":    "50",
+		"Drained":   "true",
+		"Labels":    `{"track":"canary"}`,
+		"Zone":      `"rack-1"`,
+		schemaField: fmt.Sprint(newer),
+	}
+	for k, v := range expected {
+		if got := string(fields[k]); got != v {
+			t.Errorf("Expected %v to be %v after re-registering, got %q", k, v, got)
+		}
+	}
+}
+
+func TestKnownFieldsAreNotUnknown(t *testing.T) {
+	// a field we understand mustn't be written back from the unknown set, whatever its case
+	i, err := decodeInstance([]byte(`{"SchemaVersion":2,"id":"instance-1","weight":10,"Drained":true}`))
+	if err != nil {
+		t.Fatalf("Failed to decode instance: %v", err)
+	}
+	if len(i.unknown) != 0 {
+		t.Errorf("Expected no unknown fields, got %v", i.unknown)
+	}
+
+	i.Weight = 20
+	i.Drained = false
+	b, err := encodeInstance(i)
+	if err != nil {
+		t.Fatalf("Failed to encode instance: %v", err)
+	}
+	if i, err = decodeInstance(b); err != nil {
+		t.Fatalf("Failed to decode instance: %v", err)
+	}
+	if i.Weight != 20 || i.Drained {
+		t.Errorf("Stale field values written back: %+v", i)
+	}
+}
+
+func TestSchemaUpgrades(t *testing.T) {
+	defer func(u map[uint32]func(map[string]json.RawMessage) error) { schemaUpgrades = u }(schemaUpgrades)
+
+	// pretend version 2 changed the meaning of Hostname, as a future version might
+	schemaUpgrades = map[uint32]func(map[string]json.RawMessage) error{
+		1: func(doc map[string]json.RawMessage) error {
+			doc["Hostname"] = json.RawMessage(`"upgraded"`)
+			return nil
+		},
+	}
+
+	i, err := decodeInstance([]byte(legacyDocument))
+	if err != nil {
+		t.Fatalf("Failed to decode legacy instance: %v", err)
+	}
+	if i.Hostname != "upgraded" {
+		t.Errorf("Expected upgrade to be applied to legacy instance, got hostname %q", i.Hostname)
+	}
+
+	// current documents are already upgraded
+	i, err = decodeInstance([]byte(`{"SchemaVersion":2,"Hostname":"host-1"}`))
+	if err != nil {
+		t.Fatalf("Failed to decode instance: %v", err)
+	}
+	if i.Hostname != "host-1" {
+		t.Errorf("Expected upgrade not to be applied to current instance, got hostname %q", i.Hostname)
+	}
+
+	schemaUpgrades[1] = func(doc map[string]json.RawMessage) error {
+		return fmt.Errorf("broken")
+	}
+	if _, err := decodeInstance([]byte(legacyDocument)); err == nil {
+		t.Errorf("Expected a failed upgrade to fail decoding")
+	}
+}
+
+func TestFormats(t *testing.T) {
+	defer func(f string) { instanceFormat = f }(instanceFormat)
+
+	legacy, err := decodeInstance([]byte(legacyDocument))
+	if err != nil {
+		t.Fatalf("Failed to decode legacy instance: %v", err)
+	}
+	legacy.unknown = map[string]json.RawMessage{"Placement": json.RawMessage(`{"rack":"r1"}`)}
+
+	for _, format := range []string{FormatJSON, FormatGzip} {
+		instanceFormat = format
+		b, err := encodeInstance(legacy)
+		if err != nil {
+			t.Fatalf("%v: failed to encode instance: %v", format, err)
+		}
+		if isJSON := strings.HasPrefix(string(b), "{"); isJSON != (format == FormatJSON) {
+			t.Errorf("%v: unexpected stored document %q", format, b)
+		}
+
+		i, err := decodeInstance(b)
+		if err != nil {
+			t.Fatalf("%v: failed to decode instance: %v", format, err)
+		}
+		if !sameRegistration(legacy, i) {
+			t.Errorf("%v: instance changed in round trip: %+v", format, i)
+		}
+		if i.schema != instanceSchema {
+			t.Errorf("%v: expected schema %v, got %v", format, instanceSchema, i.schema)
+		}
+		if got := string(i.unknown["Placement"]); got != `{"rack":"r1"}` {
+			t.Errorf("%v: expected unknown field to survive, got %q", format, got)
+		}
+	}
+
+	// plain JSON is always readable, whatever we write
+	instanceFormat = FormatGzip
+	if _, err := decodeInstance([]byte(legacyDocument)); err != nil {
+		t.Errorf("Failed to decode plain JSON whilst writing gzip: %v", err)
+	}
+}
+
+func TestDecodeInvalidDocuments(t *testing.T) {
+	testCases := []struct {
+		desc string
+		doc  string
+	}{
+		{"unknown format", encodingMagic + "\x7f{}"},
+		{"truncated header", encodingMagic},
+		{"corrupt gzip", encodingMagic + "\x01not gzip"},
+		{"invalid schema version", `{"SchemaVersion":"two"}`},
+		{"not JSON", `not JSON`},
+	}
+
+	for _, tc := range testCases {
+		if _, err := decodeInstance([]byte(tc.doc)); err == nil {
+			t.Errorf("%v: expected an error", tc.desc)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package registry
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	"github.com/HailoOSS/service/config"
+)
+
+const (
+	// AllRegions can be supplied in place of a region name to query every known region
+	AllRegions = "all"
+
+	peerSessionTimeout = 10 * time.Second
+	peerRetryDelay     = 10 * time.Second
+)
+
+// ErrUnknownRegion is returned when asking for a region we are not federated with
+var ErrUnknownRegion = fmt.Errorf("Unknown region")
+
+// federationConfig is loaded from hailo.service.discovery.federation, eg:
+//
+//	{"region": "eu-west-1", "peers": {"us-east-1": ["zk1.us-east-1:2181", "zk2.us-east-1:2181"]}}
+type federationConfig struct {
+	Region string
+	Peers  map[string][]string
+}
+
+func loadFederationConfig() *federationConfig {
+	cfg := &federationConfig{}
+	if err := config.AtPath("hailo", "service", "discovery", "federation").AsStruct(cfg); err != nil {
+		log.Warnf("[Discovery] Failed to load federation config: %v", err)
+	}
+	return cfg
+}
+
+// validate returns the peers that are configured sensibly, along with why any others aren't
+func (cfg *federationConfig) validate() (map[string][]string, []string) {
+	valid := make(map[string][]string)
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(cfg.Peers) == 0 {
+		return valid, nil
+	}
+	if cfg.Region == "" {
+		problem("region is required to federate with peers")
+		return valid, problems
+	}
+
+	// an ensemble listed for more than one peer is the same region under two names, or a typo
+	owners := make(map[string][]string)
+	for name, hosts := range cfg.Peers {
+		for _, host := range hosts {
+			owners[host] = append(owners[host], name)
+		}
+	}
+
+	for name, hosts := range cfg.Peers {
+		switch {
+		case !ValidNodeName(name):
+			problem("peer %q is not a valid region name", name)
+			continue
+		case name == cfg.Region:
+			problem("peer %v is our own region", name)
+			continue
+		case len(hosts) == 0:
+			problem("peer %v has no hosts", name)
+			continue
+		}
+		ok := true
+		for _, host := range hosts {
+			if err := validHostPort(host); err != nil {
+				problem("peer %v has invalid host %q: %v", name, host, err)
+				ok = false
+			} else if len(owners[host]) > 1 {
+				problem("peer %v shares host %v with another peer", name, host)
+				ok = false
+			}
+		}
+		if ok {
+			valid[name] = hosts
+		}
+	}
+	sort.Strings(problems)
+	return valid, problems
+}
+
+// validHostPort checks a ZK server address is a host and numeric port
+func validHostPort(hostport string) error {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// federation keeps read-only replicas of peer regions alongside our own region
+type federation struct {
+	sync.RWMutex
+	peers map[string]*regionReg
+}
+
+func newFederation(cfg *federationConfig) *federation {
+	f := &federation{
+		peers: make(map[string]*regionReg),
+	}
+	peers, problems := cfg.validate()
+	for _, p := range problems {
+		log.Errorf("[Discovery] Ignoring misconfigured federation: %v", p)
+	}
+	for name, hosts := range peers {
+		f.peers[name] = newPeerRegionReg(name, hosts)
+	}
+	return f
+}
+
+// newPeerRegionReg mints a read-only region registry kept in sync with a peer region's ZK ensemble
+func newPeerRegionReg(name string, hosts []string) *regionReg {
+	r := emptyRegionReg(name)
+
+	go r.peerSyncer(hosts)
+
+	return r
+}
+
+// connectPeer connects to a peer region's ZK ensemble, returning a function to close the connection
+var connectPeer = func(name string, hosts []string) (zkConn, func(), error) {
+	conn, events, err := gozk.Connect(hosts, peerSessionTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := acls.authenticate(conn); err != nil {
+		log.Warnf("[Discovery] Failed to authenticate with peer region %v: %v", name, err)
+	}
+	go func() {
+		for _ = range events {
+		}
+	}()
+	return conn, conn.Close, nil
+}
+
+// peerSyncer will continually sync with a peer region, reconnecting on failure; unlike our own
+// region, losing a peer isn't fatal so we simply keep serving the last known state
+func (r *regionReg) peerSyncer(hosts []string) {
+	log.Debugf("[Discovery] Launching syncer for peer region %v...", r.name)
+	for {
+		conn, closeConn, err := connectPeer(r.name, hosts)
+		if err == nil {
+			err = r.watch(conn)
+			closeConn()
+		}
+
+		log.Warnf("[Discovery] Lost sync with peer region %v: %v -- delaying for %v", r.name, err, peerRetryDelay)
+		time.Sleep(peerRetryDelay)
+	}
+}
+
+// regions returns the names of all regions we know about, including our own
+func (f *federation) regions() []string {
+	f.RLock()
+	defer f.RUnlock()
+	ret := []string{region.name}
+	for name := range f.peers {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret[1:])
+	return ret
+}
+
+// instances returns all instances within a region, or every region we know about
+func (f *federation) instances(name string) (Instances, error) {
+	if name == "" || name == region.name {
+		return region.allInstances(), nil
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	if name == AllRegions {
+		ret := region.allInstances()
+		for _, r := range f.peers {
+			ret = append(ret, r.allInstances()...)
+		}
+		return ret, nil
+	}
+
+	if r, ok := f.peers[name]; ok {
+		return r.allInstances(), nil
+	}
+
+	return nil, ErrUnknownRegion
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFederationConfigValidate(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		cfg      *federationConfig
+		valid    []string
+		problems int
+	}{
+		{"no peers", &federationConfig{Region: "eu-west-1"}, nil, 0},
+		{"one peer", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"us-east-1": {"zk1.us-east-1:2181", "zk2.us-east-1:2181"}},
+		}, []string{"us-east-1"}, 0},
+		{"no region", &federationConfig{
+			Peers: map[string][]string{"us-east-1": {"zk1.us-east-1:2181"}},
+		}, nil, 1},
+		{"our own region", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"eu-west-1": {"zk1.eu-west-1:2181"}},
+		}, nil, 1},
+		{"invalid name", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"us/east-1": {"zk1.us-east-1:2181"}},
+		}, nil, 1},
+		{"no hosts", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"us-east-1": {}},
+		}, nil, 1},
+		{"missing port", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"us-east-1": {"zk1.us-east-1"}},
+		}, nil, 1},
+		{"invalid port", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"us-east-1": {"zk1.us-east-1:zk"}},
+		}, nil, 1},
+		{"missing host", &federationConfig{
+			Region: "eu-west-1",
+			Peers:  map[string][]string{"us-east-1": {":2181"}},
+		}, nil, 1},
+		{"one bad peer amongst good", &federationConfig{
+			Region: "eu-west-1",
+			Peers: map[string][]string{
+				"us-east-1":      {"zk1.us-east-1:2181"},
+				"ap-southeast-1": {"zk1.ap-southeast-1:99999"},
+			},
+		}, []string{"us-east-1"}, 1},
+		{"shared ensemble", &federationConfig{
+			Region: "eu-west-1",
+			Peers: map[string][]string{
+				"us-east-1": {"zk1.us-east-1:2181"},
+				"us-west-1": {"zk1.us-east-1:2181"},
+			},
+		}, nil, 2},
+	}
+
+	for _, tc := range testCases {
+		valid, problems := tc.cfg.validate()
+		if len(valid) != len(tc.valid) {
+			t.Errorf("%v: expected peers %v, got %v", tc.desc, tc.valid, valid)
+		}
+		for _, name := range tc.valid {
+			if _, ok := valid[name]; !ok {
+				t.Errorf("%v: expected peer %v to be valid, got %v", tc.desc, name, valid)
+			}
+		}
+		if len(problems) != tc.problems {
+			t.Errorf("%v: expected %v problems, got %q", tc.desc, tc.problems, problems)
+		}
+	}
+}
+
+func TestPeerRegionReplica(t *testing.T) {
+	defer func(r *regionReg, c func(string, []string) (zkConn, func(), error)) {
+		region, connectPeer = r, c
+	}(region, connectPeer)
+	region = emptyRegionReg("eu-west-1")
+
+	z := newFakeZk()
+	z.put(t, &Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"})
+	z.putService(t, &Instance{Id: "instance-2", Name: "com.HailoOSS.service.bar"})
+	connected := make(chan []string, 1)
+	connectPeer = func(name string, hosts []string) (zkConn, func(), error) {
+		connected <- hosts
+		return z, func() {}, nil
+	}
+
+	f := newFederation(&federationConfig{
+		Region: "eu-west-1",
+		Peers: map[string][]string{
+			"us-east-1": {"zk1.us-east-1:2181"},
+			"eu-west-1": {"zk1.eu-west-1:2181"},
+		},
+	})
+	expectOrder(t, "regions", []string{"eu-west-1", "us-east-1"}, f.regions())
+	select {
+	case hosts := <-connected:
+		expectOrder(t, "peer hosts", []string{"zk1.us-east-1:2181"}, hosts)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected to connect to peer region")
+	}
+
+	// both layouts are replicated, tagged with the peer's region
+	var insts Instances
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		insts, _ = f.instances("us-east-1")
+		if len(insts) >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(insts) != 2 {
+		t.Fatalf("Expected 2 instances replicated from peer, got %v", len(insts))
+	}
+	for _, inst := range insts {
+		if inst.Region != "us-east-1" {
+			t.Errorf("Expected %v to be tagged with its region, got %q", inst.Id, inst.Region)
+		}
+	}
+	if _, err := f.instances("ap-southeast-1"); err != ErrUnknownRegion {
+		t.Errorf("Expected ErrUnknownRegion for a region we don't federate with, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package registry
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/service/config"
+)
+
+const (
+	journalPrefix   = "journal-"
+	journalSuffix   = ".log"
+	journalMaxSize  = 10 * 1024 * 1024
+	journalMaxFiles = 10
+	// journalDir is where we journal unless configured otherwise; it must survive reboots, since
+	// consumers replay from it after we restart
+	journalDir = "/var/lib/discovery-service/journal"
+
+	// MaxReplay is the most events we'll return from a single replay
+	MaxReplay = 1000
+)
+
+// EventType is the kind of thing that happened to an instance
+type EventType string
+
+const (
+	EventUp      EventType = "up"
+	EventDown    EventType = "down"
+	EventDrained EventType = "drained"
+	EventSuspect EventType = "suspect"
+	EventUpdated EventType = "updated"
+)
+
+// Event is a single journalled change to the registry
+type Event struct {
+	Seq            uint64
+	Timestamp      time.Time
+	Type           EventType
+	InstanceId     string
+	Hostname       string
+	ServiceName    string
+	ServiceVersion uint64
+	AzName         string
+	Reason         Reason `json:",omitempty"`
+	// Registered is when the instance's node was created, which identifies this registration of it
+	Registered time.Time
+}
+
+func newEvent(t EventType, inst *Instance) *Event {
+	return &Event{
+		Type:           t,
+		InstanceId:     inst.Id,
+		Hostname:       inst.Hostname,
+		ServiceName:    inst.Name,
+		ServiceVersion: inst.Version,
+		AzName:         inst.AzName,
+		Registered:     inst.Registered,
+	}
+}
+
+// eventJournal is an append-only, size-rotated log of events on local disk, one JSON event per line.
+// Every discovery node journals what it sees, so sequence numbers are only meaningful per node.
+type eventJournal struct {
+	sync.Mutex
+	dir string
+	// maxSize is the size at which we rotate, and maxFiles how many files we keep
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+	seq      uint64
+}
+
+func newEventJournal(dir string) (*eventJournal, error) {
+	j := &eventJournal{
+		dir:      dir,
+		maxSize:  journalMaxSize,
+		maxFiles: journalMaxFiles,
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// carry on numbering from wherever we left off
+	files, err := j.files()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		last := files[len(files)-1]
+		err := readJournalFile(last, func(e *Event) bool {
+			j.seq = e.Seq
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if j.f, err = os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return nil, err
+		}
+		fi, err := j.f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		j.size = fi.Size()
+	}
+
+	return j, nil
+}
+
+// loadJournal opens the journal at the configured location; failing to do so isn't fatal, we just won't journal
+func loadJournal() *eventJournal {
+	dir := config.AtPath("hailo", "service", "discovery", "journal", "dir").AsString(journalDir)
+	j, err := newEventJournal(dir)
+	if err != nil {
+		log.Errorf("[Discovery] Failed to open event journal at %v, events will not be journalled: %v", dir, err)
+		return nil
+	}
+	return j
+}
+
+// append records an event, assigning its sequence number and timestamp
+func (j *eventJournal) append(e *Event) {
+	if j == nil {
+		return
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	e.Seq = j.seq + 1
+	e.Timestamp = time.Now()
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Warnf("[Discovery] Failed to marshal event JSON: %v", err)
+		return
+	}
+	b = append(b, '\n')
+
+	if j.f == nil || j.size+int64(len(b)) > j.maxSize {
+		if err := j.rotate(e.Seq); err != nil {
+			log.Warnf("[Discovery] Failed to rotate event journal: %v", err)
+			return
+		}
+	}
+
+	n, err := j.f.Write(b)
+	j.size += int64(n)
+	if err != nil {
+		log.Warnf("[Discovery] Failed to journal event %v: %v", e.Seq, err)
+		return
+	}
+	j.seq = e.Seq
+}
+
+// rotate starts a new journal file, named for the first sequence it will hold, and removes the oldest
+// files beyond maxFiles
+func (j *eventJournal) rotate(firstSeq uint64) error {
+	if j.f != nil {
+		j.f.Close()
+		j.f = nil
+	}
+
+	path := filepath.Join(j.dir, fmt.Sprintf("%v%020d%v", journalPrefix, firstSeq, journalSuffix))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	j.f = f
+	j.size = 0
+
+	files, err := j.files()
+	if err != nil {
+		return err
+	}
+	for len(files) > j.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// files returns all journal files, oldest first
+func (j *eventJournal) files() ([]string, error) {
+	infos, err := ioutil.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0)
+	for _, fi := range infos {
+		if strings.HasPrefix(fi.Name(), journalPrefix) && strings.HasSuffix(fi.Name(), journalSuffix) {
+			ret = append(ret, filepath.Join(j.dir, fi.Name()))
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// replay returns up to limit events after sequence number sinceSeq, or at/after since, whichever is later,
+// plus the last sequence number we have journalled
+func (j *eventJournal) replay(sinceSeq uint64, since time.Time, limit int) ([]*Event, uint64, error) {
+	if j == nil {
+		return nil, 0, fmt.Errorf("Event journal unavailable")
+	}
+	if limit <= 0 || limit > MaxReplay {
+		limit = MaxReplay
+	}
+
+	j.Lock()
+	files, err := j.files()
+	lastSeq := j.seq
+	j.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// files are read without the lock, so the oldest may be rotated away before we get to them; their
+	// events are gone either way, so skip them
+	ret := make([]*Event, 0)
+	for _, f := range files {
+		err := readJournalFile(f, func(e *Event) bool {
+			if e.Seq > sinceSeq && !e.Timestamp.Before(since) {
+				ret = append(ret, e)
+			}
+			return len(ret) < limit
+		})
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if len(ret) >= limit {
+			break
+		}
+	}
+
+	return ret, lastSeq, nil
+}
+
+// readJournalFile calls fn with each event in a journal file until fn returns false; a partially
+// written final line (eg: from a crash) is ignored
+func readJournalFile(path string, fn func(e *Event) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := &Event{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			log.Warnf("[Discovery] Skipping corrupt event in %v: %v", path, err)
+			continue
+		}
+		if !fn(e) {
+			break
+		}
+	}
+	return scanner.Err()
+}
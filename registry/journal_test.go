@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// tempJournal opens a journal in a fresh directory, returning a function to remove it afterwards
+func tempJournal(t *testing.T) (*eventJournal, func()) {
+	dir, err := ioutil.TempDir("", "discovery-journal")
+	if err != nil {
+		t.Fatalf("Failed to create journal dir: %v", err)
+	}
+	j, err := newEventJournal(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	return j, func() { os.RemoveAll(dir) }
+}
+
+func journalEvents(j *eventJournal, n int) {
+	for i := 0; i < n; i++ {
+		j.append(&Event{Type: EventUp, InstanceId: fmt.Sprintf("instance-%v", i)})
+	}
+}
+
+func TestJournalRotate(t *testing.T) {
+	j, cleanup := tempJournal(t)
+	defer cleanup()
+
+	// each event is well over 100 bytes, so every one starts a new file
+	j.maxSize = 100
+	j.maxFiles = 3
+	journalEvents(j, 10)
+
+	files, err := j.files()
+	if err != nil {
+		t.Fatalf("Failed to list journal files: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected only the newest 3 files to be kept, got %v", files)
+	}
+
+	events, lastSeq, err := j.replay(0, time.Time{}, MaxReplay)
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if lastSeq != 10 {
+		t.Errorf("Expected last sequence 10, got %v", lastSeq)
+	}
+	var seqs []uint64
+	for _, e := range events {
+		seqs = append(seqs, e.Seq)
+	}
+	expectOrder(t, "surviving events", []uint64{8, 9, 10}, seqs)
+}
+
+func TestJournalReplay(t *testing.T) {
+	j, cleanup := tempJournal(t)
+	defer cleanup()
+
+	journalEvents(j, 5)
+	mid := time.Now()
+	time.Sleep(time.Millisecond)
+	journalEvents(j, 5)
+
+	testCases := []struct {
+		desc     string
+		sinceSeq uint64
+		since    time.Time
+		limit    int
+		expected []uint64
+	}{
+		{"everything", 0, time.Time{}, 0, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"after a sequence", 7, time.Time{}, 0, []uint64{8, 9, 10}},
+		{"since a time", 0, mid, 0, []uint64{6, 7, 8, 9, 10}},
+		{"whichever is later", 8, mid, 0, []uint64{9, 10}},
+		{"limited", 0, time.Time{}, 2, []uint64{1, 2}},
+		{"nothing newer", 10, time.Time{}, 0, nil},
+	}
+
+	for _, tc := range testCases {
+		events, lastSeq, err := j.replay(tc.sinceSeq, tc.since, tc.limit)
+		if err != nil {
+			t.Fatalf("%v: failed to replay journal: %v", tc.desc, err)
+		}
+		if lastSeq != 10 {
+			t.Errorf("%v: expected last sequence 10, got %v", tc.desc, lastSeq)
+		}
+		var seqs []uint64
+		for _, e := range events {
+			seqs = append(seqs, e.Seq)
+		}
+		expectOrder(t, tc.desc, tc.expected, seqs)
+	}
+}
+
+func TestJournalResumesSequence(t *testing.T) {
+	j, cleanup := tempJournal(t)
+	defer cleanup()
+
+	journalEvents(j, 3)
+	j.f.Close()
+
+	// as if we restarted
+	j, err := newEventJournal(j.dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen journal: %v", err)
+	}
+	journalEvents(j, 2)
+
+	events, lastSeq, err := j.replay(0, time.Time{}, MaxReplay)
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if lastSeq != 5 {
+		t.Errorf("Expected last sequence 5, got %v", lastSeq)
+	}
+	var seqs []uint64
+	for _, e := range events {
+		seqs = append(seqs, e.Seq)
+	}
+	expectOrder(t, "resumed events", []uint64{1, 2, 3, 4, 5}, seqs)
+}
+
+func TestJournalReplaySkipsRemovedFiles(t *testing.T) {
+	j, cleanup := tempJournal(t)
+	defer cleanup()
+
+	j.maxSize = 100
+	journalEvents(j, 3)
+
+	// a file listed before it was rotated away reads as missing, which mustn't fail the replay
+	files, err := j.files()
+	if err != nil {
+		t.Fatalf("Failed to list journal files: %v", err)
+	}
+	if err := os.Remove(files[0]); err != nil {
+		t.Fatalf("Failed to remove journal file: %v", err)
+	}
+	if err := readJournalFile(files[0], func(e *Event) bool { return true }); !os.IsNotExist(err) {
+		t.Fatalf("Expected reading a removed file to fail as not existing, got %v", err)
+	}
+
+	events, _, err := j.replay(0, time.Time{}, MaxReplay)
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("Expected the 2 remaining events, got %v", len(events))
+	}
+}
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	"github.com/HailoOSS/service/config"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+// Instances are stored in one of two layouts beneath rootNode:
+//
+//   - flat, where each instance is a direct child: /discovery-service/<instanceId>
+//   - hierarchical, where instances are grouped under a node per service:
+//     /discovery-service/<service>/<instanceId>
+//
+// We always read both, telling them apart since service nodes are persistent and hold no data, and
+// write whichever layout is configured. This lets every discovery node learn to read the hierarchical
+// layout before any start writing it; flat instances then disappear as they re-register.
+const (
+	serviceNode         = "/discovery-service/%v"
+	serviceInstanceNode = "/discovery-service/%v/%v"
+)
+
+// hierarchical is whether we register new instances in the hierarchical layout
+var hierarchical bool
+
+// validNodeName is what we accept as a node name taken from a request, such as an instance ID or service
+// name: stricter than ZK itself, so nothing can add path components ("/"), name "." or "..", or use the
+// characters ZK disallows
+var validNodeName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,254}$`)
+
+// ValidNodeName returns whether name can safely be used as a node name, eg: an instance ID or service name
+func ValidNodeName(name string) bool {
+	return validNodeName.MatchString(name)
+}
+
+func loadLayout() bool {
+	return config.AtPath("hailo", "service", "discovery", "layout", "hierarchical").AsBool()
+}
+
+// zkPathForService gets path of the node holding all instances of a service, in the hierarchical layout
+func zkPathForService(service string) string {
+	return fmt.Sprintf(serviceNode, service)
+}
+
+// zkPathForServiceInstance gets path for an instance of a service, in the hierarchical layout
+func zkPathForServiceInstance(service, instanceId string) string {
+	return fmt.Sprintf(serviceInstanceNode, service, instanceId)
+}
+
+// instancePath returns where an instance is stored, if we know, otherwise assuming the flat layout
+func instancePath(instanceId string) string {
+	if path := region.pathOf(instanceId); path != "" {
+		return path
+	}
+	return zkPathForInstance(instanceId)
+}
+
+// isServiceNode returns whether a child of rootNode holds the instances of a service, rather than
+// being an instance itself
+func isServiceNode(stat *gozk.Stat) bool {
+	return stat.EphemeralOwner == 0 && stat.DataLength == 0
+}
+
+// parentOf returns the path of a node's parent
+func parentOf(path string) string {
+	return path[:strings.LastIndex(path, "/")]
+}
+
+// nameOf returns the last element of a node's path
+func nameOf(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// createInstanceNode creates an instance's ephemeral node, plus the service node above it in the
+// hierarchical layout, which the leader may reap at any moment once it is empty
+func createInstanceNode(path string, b []byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = zk.Create(path, b, gozk.FlagEphemeral, acls.forPath(path))
+		if err != gozk.ErrNoNode {
+			return err
+		}
+		parent := parentOf(path)
+		if _, err := zk.Create(parent, []byte{}, 0, acls.forPath(parent)); err != nil && err != gozk.ErrNodeExists {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,462 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const (
+	electionNode   = "/discovery-service-election"
+	electionPrefix = "/discovery-service-election/node-"
+	electionDelay  = 5 * time.Second
+
+	presenceNode = "/discovery-service-nodes"
+	presencePath = "/discovery-service-nodes/%v"
+
+	reapInterval    = time.Minute
+	reapGrace       = 2 * maxHeartbeatDiff
+	summaryInterval = time.Minute
+)
+
+// ErrNoLeader is returned when no discovery node currently holds leadership
+var ErrNoLeader = fmt.Errorf("No discovery node is currently leader")
+
+// Node is a single discovery service node taking part in the leader election
+type Node struct {
+	Id       string
+	Hostname string
+	Leader   bool `json:"-"`
+	session  int64
+}
+
+// leaderTask is a chore that is only run by whichever discovery node is currently leader
+type leaderTask struct {
+	name     string
+	interval time.Duration
+	fn       func() error
+}
+
+type election struct {
+	sync.RWMutex
+	self    *Node
+	path    string
+	leading bool
+	tasks   []*leaderTask
+	// count is how many nodes are taking part in the election, kept up to date by watchNodes; -1 until
+	// we have first read it
+	count int
+}
+
+func newElection(self *Node) *election {
+	e := &election{
+		self:  self,
+		count: -1,
+	}
+
+	ensureNode(electionNode)
+	ensureNode(presenceNode)
+
+	go e.presence()
+	go e.watchNodes()
+
+	return e
+}
+
+// start takes part in the election, once everything we need to lead is initialised
+func (e *election) start() {
+	go e.elector()
+}
+
+// elector continually takes part in the election, contending again whenever our node is lost
+func (e *election) elector() {
+	log.Debug("[Discovery] Launching elector...")
+	for {
+		if err := e.contend(); err != nil {
+			log.Warnf("[Discovery] Leader election failed: %v -- delaying for %v", err, electionDelay)
+		}
+		e.setLeading(false)
+		time.Sleep(electionDelay)
+	}
+}
+
+// contend creates our election node and then waits until we either become leader or
+// lose our node; it only returns on failure or when our node disappears
+func (e *election) contend() error {
+	b, err := json.Marshal(e.self)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal node JSON: %v", err)
+	}
+	path, err := zk.CreateProtectedEphemeralSequential(electionPrefix, b, acls.forPath(electionPrefix))
+	if err != nil {
+		return fmt.Errorf("Failed to create election node: %v", err)
+	}
+	e.Lock()
+	e.path = path
+	e.Unlock()
+	defer zk.Delete(path, -1)
+
+	for {
+		children, _, err := zk.Children(electionNode)
+		if err != nil {
+			return err
+		}
+		sortBySequence(children)
+
+		idx := -1
+		for i, c := range children {
+			if electionNode+"/"+c == path {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("Election node %v has gone away", path)
+		}
+
+		// lowest sequence leads; everyone else watches the node immediately ahead of them
+		watchPath := path
+		if idx > 0 {
+			watchPath = electionNode + "/" + children[idx-1]
+		}
+		exists, _, watch, err := zk.ExistsW(watchPath)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		if idx == 0 && !e.isLeader() {
+			log.Infof("[Discovery] Elected leader of region as %v", e.self.Id)
+			takeLead(func() { e.setLeading(true) })
+		}
+
+		ev := <-watch
+		log.Debugf("[Discovery] Election watch triggered for event %v", ev)
+	}
+}
+
+// presence keeps an ephemeral node for us for as long as our ZK session lasts, recreating it whenever
+// the session is lost. Unlike our election node it survives us dropping out of the election to retry,
+// so it is what tells the leader which instances still have a live owner.
+func (e *election) presence() {
+	path := fmt.Sprintf(presencePath, e.self.Id)
+	b, err := json.Marshal(e.self)
+	if err != nil {
+		log.Errorf("[Discovery] Failed to marshal node JSON: %v", err)
+		return
+	}
+
+	for {
+		_, err := zk.Create(path, b, gozk.FlagEphemeral, acls.forPath(path))
+		if err != nil && err != gozk.ErrNodeExists {
+			log.Warnf("[Discovery] Failed to create presence node: %v -- delaying for %v", err, electionDelay)
+			time.Sleep(electionDelay)
+			continue
+		}
+
+		exists, _, watch, err := zk.ExistsW(path)
+		if err != nil {
+			log.Warnf("[Discovery] Failed to watch presence node: %v -- delaying for %v", err, electionDelay)
+			time.Sleep(electionDelay)
+			continue
+		}
+		if exists {
+			ev := <-watch
+			log.Debugf("[Discovery] Presence watch triggered for event %v", ev)
+		}
+	}
+}
+
+// watchNodes keeps count up to date with the number of nodes taking part in the election, so it can be
+// reported without reading ZK
+func (e *election) watchNodes() {
+	log.Debug("[Discovery] Launching election watcher...")
+	for {
+		children, _, watch, err := zk.ChildrenW(electionNode)
+		if err != nil {
+			log.Warnf("[Discovery] Failed to watch election: %v -- delaying for %v", err, electionDelay)
+			time.Sleep(electionDelay)
+			continue
+		}
+
+		e.Lock()
+		e.count = len(children)
+		e.Unlock()
+
+		ev := <-watch
+		log.Debugf("[Discovery] Election children watch triggered for event %v", ev)
+	}
+}
+
+// nodeCount returns how many discovery nodes are taking part in the election, as of our last watch,
+// and whether we have counted them yet
+func (e *election) nodeCount() (int, bool) {
+	e.RLock()
+	defer e.RUnlock()
+	if e.count < 0 {
+		return 0, false
+	}
+	return e.count, true
+}
+
+func (e *election) setLeading(leading bool) {
+	e.Lock()
+	defer e.Unlock()
+	if e.leading && !leading {
+		log.Infof("[Discovery] Stepping down as leader of region")
+	}
+	e.leading = leading
+}
+
+// isLeader returns whether this discovery node is currently leader
+func (e *election) isLeader() bool {
+	e.RLock()
+	defer e.RUnlock()
+	return e.leading
+}
+
+// errTaskDone is returned by a leader task that has nothing left to do, so that it isn't run again
+var errTaskDone = fmt.Errorf("Nothing left to do")
+
+// addTask schedules a chore that will run every interval, but only whilst we are leader, until it
+// returns errTaskDone
+func (e *election) addTask(name string, interval time.Duration, fn func() error) {
+	t := &leaderTask{
+		name:     name,
+		interval: interval,
+		fn:       fn,
+	}
+
+	e.Lock()
+	e.tasks = append(e.tasks, t)
+	e.Unlock()
+
+	go func() {
+		tick := time.NewTicker(t.interval)
+		defer tick.Stop()
+		for {
+			<-tick.C
+			if !e.isLeader() {
+				continue
+			}
+			if err := t.fn(); err == errTaskDone {
+				log.Infof("[Discovery] Leader task %v has nothing left to do, so stopping", t.name)
+				return
+			} else if err != nil {
+				log.Warnf("[Discovery] Leader task %v failed: %v", t.name, err)
+			}
+		}
+	}()
+}
+
+// nodes returns all discovery nodes taking part in the election, in order of seniority
+func (e *election) nodes() ([]*Node, error) {
+	children, _, err := zk.Children(electionNode)
+	if err != nil {
+		return nil, err
+	}
+	sortBySequence(children)
+
+	ret := make([]*Node, 0, len(children))
+	for _, c := range children {
+		b, stat, err := zk.Get(electionNode + "/" + c)
+		if err == gozk.ErrNoNode {
+			// gone since we listed
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		n := &Node{}
+		if err := json.Unmarshal(b, n); err != nil {
+			return nil, err
+		}
+		n.session = stat.EphemeralOwner
+		n.Leader = len(ret) == 0
+		ret = append(ret, n)
+	}
+
+	return ret, nil
+}
+
+// leader returns the discovery node which currently holds leadership
+func (e *election) leader() (*Node, error) {
+	nodes, err := e.nodes()
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrNoLeader
+	}
+	return nodes[0], nil
+}
+
+// sortBySequence orders election node names by their sequence suffix, ignoring any protection prefix
+func sortBySequence(children []string) {
+	sort.Sort(bySequence(children))
+}
+
+type bySequence []string
+
+func (s bySequence) Len() int      { return len(s) }
+func (s bySequence) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySequence) Less(i, j int) bool {
+	return sequence(s[i]) < sequence(s[j])
+}
+
+func sequence(name string) string {
+	if idx := strings.LastIndex(name, "-"); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// ---
+
+// liveSessions returns the ZK sessions of every running discovery node, going by their presence nodes,
+// plus their election nodes in case any are running a version without presence nodes
+func liveSessions() (map[int64]bool, error) {
+	nodes, err := leader.nodes()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make(map[int64]bool)
+	for _, n := range nodes {
+		sessions[n.session] = true
+	}
+
+	ids, _, err := zk.Children(presenceNode)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		exists, stat, err := zk.Exists(presenceNode + "/" + id)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			sessions[stat.EphemeralOwner] = true
+		}
+	}
+
+	return sessions, nil
+}
+
+// reapStaleInstances removes instance nodes that aren't owned by any live discovery node - for example
+// persistent nodes left behind by tooling, or those whose owner's session has gone - plus service
+// nodes that have emptied
+func reapStaleInstances() error {
+	sessions, err := liveSessions()
+	if err != nil {
+		return err
+	}
+
+	children, _, err := zk.Children(rootNode)
+	if err != nil {
+		return err
+	}
+
+	cutOff := time.Now().Add(-reapGrace)
+	for _, child := range children {
+		path := rootNode + "/" + child
+		exists, stat, err := zk.Exists(path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if isServiceNode(stat) {
+			if err := reapStaleService(path, sessions, cutOff); err != nil {
+				return err
+			}
+			continue
+		}
+		reapStaleInstance(child, path, stat, sessions, cutOff)
+	}
+
+	return nil
+}
+
+// reapStaleService reaps stale instances of a service, in the hierarchical layout, then the service
+// node itself if that leaves it empty
+func reapStaleService(path string, sessions map[int64]bool, cutOff time.Time) error {
+	ids, _, err := zk.Children(path)
+	if err == gozk.ErrNoNode {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		instPath := path + "/" + id
+		exists, stat, err := zk.Exists(instPath)
+		if err != nil {
+			return err
+		}
+		if exists {
+			reapStaleInstance(id, instPath, stat, sessions, cutOff)
+		}
+	}
+
+	// anyone registering will recreate it if we delete it from under them, and we can't delete it
+	// once they have
+	exists, stat, err := zk.Exists(path)
+	if err != nil {
+		return err
+	}
+	if !exists || stat.NumChildren > 0 || time.Unix(0, stat.Ctime*int64(time.Millisecond)).After(cutOff) {
+		return nil
+	}
+	if err := zk.Delete(path, stat.Version); err != nil && err != gozk.ErrNoNode && err != gozk.ErrNotEmpty {
+		log.Warnf("[Discovery] Failed to reap empty service node %v: %v", path, err)
+	}
+	return nil
+}
+
+// reapStaleInstance removes an instance if it isn't owned by any live discovery node
+func reapStaleInstance(id, path string, stat *gozk.Stat, sessions map[int64]bool, cutOff time.Time) {
+	if sessions[stat.EphemeralOwner] {
+		return
+	}
+	if time.Unix(0, stat.Mtime*int64(time.Millisecond)).After(cutOff) {
+		return
+	}
+
+	log.Infof("[Discovery] Reaping stale instance %v (owner session %v)", id, stat.EphemeralOwner)
+	if err := buryInstance(id, &tombstone{Reason: ReasonEviction}); err != nil {
+		log.Warnf("[Discovery] Failed to write tombstone for %v: %v", id, err)
+	}
+	if err := zk.Delete(path, stat.Version); err != nil && err != gozk.ErrNoNode {
+		log.Warnf("[Discovery] Failed to reap stale instance %v: %v", id, err)
+	}
+}
+
+// logRegionSummary emits a brief census of the region
+func logRegionSummary() error {
+	nodes, err := leader.nodes()
+	if err != nil {
+		return err
+	}
+
+	instances := region.allInstances()
+	services := make(map[string]bool)
+	hosts := make(map[string]bool)
+	for _, inst := range instances {
+		services[inst.Name] = true
+		hosts[inst.Hostname] = true
+	}
+
+	log.Infof("[Discovery] Region summary: %v instances of %v services on %v hosts, %v discovery nodes",
+		len(instances), len(services), len(hosts), len(nodes))
+
+	return nil
+}
@@ -1,7 +1,6 @@
 package registry
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"sync"
@@ -11,13 +10,14 @@ import (
 	"github.com/nu7hatch/gouuid"
 
 	"github.com/HailoOSS/discovery-service/heartbeat"
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
 	"github.com/HailoOSS/platform/raven"
 	zk "github.com/HailoOSS/service/zookeeper"
-	gozk "github.com/HailoOSS/go-zookeeper/zk"
 )
 
 const (
 	heartbeatInterval = 10 * time.Second
+	suspectAfter      = 2 * heartbeatInterval
 	maxHeartbeatDiff  = 30 * time.Second
 	initAttempts      = 30
 	initDelay         = time.Second
@@ -26,13 +26,18 @@ const (
 type localReg struct {
 	sync.RWMutex
 	aliveInstances map[string]*heartbeat.Heartbeat
-	id             string
-	hostname       string
+	suspects       map[string]bool
+	// paths is where each of our instances is stored
+	paths    map[string]string
+	id       string
+	hostname string
 }
 
 func newLocalReg() *localReg {
 	r := &localReg{
 		aliveInstances: make(map[string]*heartbeat.Heartbeat),
+		suspects:       make(map[string]bool),
+		paths:          make(map[string]string),
 	}
 	r.hostname, _ = os.Hostname()
 	uuid, _ := uuid.NewV4()
@@ -40,21 +45,42 @@ func newLocalReg() *localReg {
 
 	log.Infof("[Discovery] Initialising local registry on %v...", r.hostname)
 
-	// create root node
+	ensureNode(rootNode)
+	ensureNode(tombstoneNode)
+
+	// listen for incoming heartbeat responses & send out heartbeats
+	go r.listenHearbeats()
+
+	// use a ticker to send HBs because we quite want them to go regularly, rather than sleeping for example
+	go func() {
+		tick := time.NewTicker(heartbeatInterval)
+		for {
+			<-tick.C
+			r.sendHeartbeats()
+		}
+
+	}()
+
+	return r
+}
+
+// ensureNode checks a persistent node exists, creating it if not, retrying a number of times
+// before giving up and exiting
+func ensureNode(path string) {
 	attempts := 0
 	for {
 		var (
 			err    error
 			exists bool
 		)
-		exists, _, err = zk.Exists(rootNode)
+		exists, _, err = zk.Exists(path)
 		if err == nil {
 			if exists {
 				break
 			}
-			log.Infof("[Discovery] Creating root node %v...", rootNode)
-			_, err = zk.Create(rootNode, []byte{}, 0, gozk.WorldACL(gozk.PermAll))
-			if err == nil {
+			log.Infof("[Discovery] Creating node %v...", path)
+			_, err = zk.Create(path, []byte{}, 0, acls.forPath(path))
+			if err == nil || err == gozk.ErrNodeExists {
 				break
 			}
 		}
@@ -62,27 +88,12 @@ func newLocalReg() *localReg {
 		// some error
 		attempts++
 		if attempts > initAttempts {
-			log.Criticalf("[Discovery] Failed to check/create root node %v times -- unable to initialise discovery service so exiting", attempts)
+			log.Criticalf("[Discovery] Failed to check/create node %v %v times -- unable to initialise discovery service so exiting", path, attempts)
 			os.Exit(3)
 		}
-		log.Warnf("[Discovery] Failed to check/create root node: %v -- delaying for %v", err, initDelay)
+		log.Warnf("[Discovery] Failed to check/create node %v: %v -- delaying for %v", path, err, initDelay)
 		time.Sleep(initDelay)
 	}
-
-	// listen for incoming heartbeat responses & send out heartbeats
-	go r.listenHearbeats()
-
-	// use a ticker to send HBs because we quite want them to go regularly, rather than sleeping for example
-	go func() {
-		tick := time.NewTicker(heartbeatInterval)
-		for {
-			<-tick.C
-			r.sendHeartbeats()
-		}
-
-	}()
-
-	return r
 }
 
 // inboundHearbeats processes deliveries from AMQP
@@ -122,9 +133,10 @@ func (r *localReg) sendHeartbeats() {
 	for _, hb := range alive {
 		// remove if not alive
 		if !hb.Healthy() {
-			go r.remove(hb.Id)
+			go r.remove(hb.Id, ReasonHeartbeatTimeout)
 			continue
 		}
+		r.suspect(hb)
 
 		if err := raven.SendHeartbeat(hb, r.id); err != nil {
 			log.Warnf("[Discovery] Error sending HB: %v", err)
@@ -132,16 +144,62 @@ func (r *localReg) sendHeartbeats() {
 	}
 }
 
-// add will add this instance to the local registry
-func (r *localReg) add(i *Instance) error {
-	b, err := json.Marshal(i)
+// suspect journals instances that have started missing heartbeats, though not yet enough to be removed
+func (r *localReg) suspect(hb *heartbeat.Heartbeat) {
+	late := time.Since(hb.Last()) > suspectAfter
+
+	r.Lock()
+	defer r.Unlock()
+	if late == r.suspects[hb.Id] {
+		return
+	}
+	if !late {
+		delete(r.suspects, hb.Id)
+		return
+	}
+	r.suspects[hb.Id] = true
+	if inst := region.singleInstance(hb.Id); inst != nil {
+		journal.append(newEvent(EventSuspect, inst))
+	}
+}
+
+// healthy returns whether a locally registered instance is passing heartbeats, and whether
+// we know about it at all (instances registered elsewhere are the concern of their owner)
+func (r *localReg) healthy(instanceId string) (healthy bool, known bool) {
+	r.RLock()
+	hb, ok := r.aliveInstances[instanceId]
+	r.RUnlock()
+	if !ok {
+		return false, false
+	}
+	return hb.Healthy(), true
+}
+
+// add will add this instance to the local registry; if it is already registered then the existing
+// registration must be the same, unless replace is set
+func (r *localReg) add(i *Instance, replace bool) error {
+	b, err := encodeInstance(i)
 	if err != nil {
-		return fmt.Errorf("[Discovery] Failed to marshal instance JSON: %v", err)
+		return err
 	}
 
-	_, err = zk.Create(i.zkPath(), b, gozk.FlagEphemeral, gozk.WorldACL(gozk.PermAll))
-	// If the node already exists then ignore the error
-	if err != gozk.ErrNodeExists && err != nil {
+	// clear out any tombstone from a previous life, so it isn't mistaken as the reason we next go away
+	if err := unburyInstance(i.Id); err != nil {
+		log.Warnf("[Discovery] Failed to remove tombstone for %v: %v", i.Id, err)
+	}
+
+	// an instance stays in whichever layout it was first registered, even if the layout changes
+	path := region.pathOf(i.Id)
+	if path == "" {
+		path = i.zkPath()
+	}
+	err = createInstanceNode(path, b)
+	if err == gozk.ErrNodeExists {
+		path, err = reregister(path, i, replace)
+	}
+	if err == ErrRegistrationConflict {
+		return err
+	} else if err != nil {
 		return fmt.Errorf("Failed to add %v to local registry: %v", i, err)
 	}
 
@@ -149,23 +207,120 @@ func (r *localReg) add(i *Instance) error {
 	r.Lock()
 	defer r.Unlock()
 	r.aliveInstances[i.Id] = heartbeat.New(i.Id, maxHeartbeatDiff)
-
-	go pubServiceUp(i)
+	r.paths[i.Id] = path
 
 	return nil
 }
 
-// remove will remove this instance ID from the local registry
-func (r *localReg) remove(instanceId string) error {
-	// try to grab instance details before we start, for broadcast msg
-	i := region.singleInstance(instanceId)
+// reregister checks an existing registration against a new one, replacing it if asked to, and returns
+// where the instance is now stored
+func reregister(path string, i *Instance, replace bool) (string, error) {
+	// in the hierarchical layout, an instance whose service changes must move to the new service's node
+	moveTo := ""
+	if parentOf(path) != rootNode {
+		if to := zkPathForServiceInstance(i.Name, i.Id); to != path {
+			moveTo = to
+		}
+	}
 
+	err := updateInstanceAt(path, i.Id, func(stored *Instance) error {
+		if sameRegistration(i, stored) {
+			return errUnchanged
+		}
+		if !replace {
+			log.Warnf("[Discovery] Conflicting registration for %v: have %v version %v, got %v version %v", i.Id, stored.Name, stored.Version, i.Name, i.Version)
+			return ErrRegistrationConflict
+		}
+		if moveTo != "" {
+			return errMoved
+		}
+		mergeRegistration(stored, i)
+		return nil
+	})
+	if err == errMoved {
+		err = moveInstance(path, moveTo, i)
+		path = moveTo
+	}
+	if err == ErrInstanceNotFound {
+		// went away whilst we were looking, so let the caller try again
+		return path, fmt.Errorf("Instance %v removed whilst registering", i.Id)
+	}
+	return path, err
+}
+
+// mergeRegistration replaces a stored document with a new registration, keeping what is adjusted after
+// registering (weight and labels, unless the registration sets them, and drain state) and anything we
+// didn't understand when decoding it, so that newer discovery nodes' fields survive
+func mergeRegistration(stored, i *Instance) {
+	weight, drained, labels := stored.Weight, stored.Drained, stored.Labels
+	schema, unknown := stored.schema, stored.unknown
+	*stored = *i
+	if stored.Weight == 0 {
+		stored.Weight = weight
+	}
+	if len(stored.Labels) == 0 {
+		stored.Labels = labels
+	}
+	stored.Drained = drained
+	stored.schema, stored.unknown = schema, unknown
+}
+
+// moveInstance moves an instance from one node to another, merging a new registration into the stored
+// document as it goes. The old node is deleted (only if unchanged since we read it) before the new one
+// is created, so the instance is never in two places at once; everyone sees it leave its old service
+// and join its new one, which is what has happened.
+func moveInstance(from, to string, i *Instance) error {
+	b, stat, err := zk.Get(from)
+	if err == gozk.ErrNoNode {
+		return ErrInstanceNotFound
+	} else if err != nil {
+		return err
+	}
+	stored, err := decodeInstance(b)
+	if err != nil {
+		return err
+	}
+	mergeRegistration(stored, i)
+	if b, err = encodeInstance(stored); err != nil {
+		return err
+	}
+
+	log.Infof("[Discovery] Moving %v from %v to %v, since its service has changed", i.Id, from, to)
+	if err := zk.Delete(from, stat.Version); err == gozk.ErrNoNode {
+		return ErrInstanceNotFound
+	} else if err == gozk.ErrBadVersion {
+		return fmt.Errorf("Instance %v changed whilst moving it, try again", i.Id)
+	} else if err != nil {
+		return err
+	}
+	return createInstanceNode(to, b)
+}
+
+// remove will remove this instance ID from the local registry, recording why for the leader to announce
+func (r *localReg) remove(instanceId string, reason Reason) error {
 	r.Lock()
 	defer r.Unlock()
 
+	t := &tombstone{Reason: reason}
+	if hb, ok := r.aliveInstances[instanceId]; ok {
+		t.LastHeartbeat = hb.Last()
+	}
+	if i := region.singleInstance(instanceId); reason == ReasonUnregister && i != nil && i.Drained {
+		t.Reason = ReasonDrained
+	}
+	if err := buryInstance(instanceId, t); err != nil {
+		log.Warnf("[Discovery] Failed to write tombstone for %v: %v", instanceId, err)
+	}
+
 	// try to delete
-	path := zkPathForInstance(instanceId)
+	path, ok := r.paths[instanceId]
+	if !ok {
+		path = instancePath(instanceId)
+	}
 	if err := zk.Delete(path, -1); err != nil {
+		// not removed, so don't leave a tombstone for some future removal to pick up
+		unburyInstance(instanceId)
+
 		// exists?
 		if exists, _, exErr := zk.Exists(path); exErr == nil && !exists {
 			// assume replay, simply carry on
@@ -177,9 +332,8 @@ func (r *localReg) remove(instanceId string) error {
 	if _, ok := r.aliveInstances[instanceId]; ok {
 		delete(r.aliveInstances, instanceId)
 	}
-	if i != nil {
-		go pubServiceDown(i)
-	}
+	delete(r.suspects, instanceId)
+	delete(r.paths, instanceId)
 
 	return nil
 }
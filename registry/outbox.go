@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/protobuf/proto"
+	inst "github.com/HailoOSS/service/instrumentation"
+)
+
+const (
+	outboxMaxPending = 10000
+	outboxAttempts   = 10
+	outboxBackoff    = 100 * time.Millisecond
+	outboxMaxBackoff = 30 * time.Second
+)
+
+// errDiscard is returned by a publisher for a message that should no longer be sent at all (eg: to a
+// webhook that has since been deleted); it is neither retried nor counted as published or failed
+var errDiscard = fmt.Errorf("Message no longer wanted")
+
+// publisher sends a single message to a topic
+type publisher interface {
+	publish(topic string, payload proto.Message) error
+}
+
+type outboxMsg struct {
+	topic   string
+	payload proto.Message
+}
+
+// outbox queues publications, retrying failures with exponential backoff. Messages sharing a key
+// (eg: an instance ID) are sent strictly in order, each waiting until the one before it has been
+// published or given up on; messages with different keys don't hold each other up.
+type outbox struct {
+	sync.Mutex
+	// name prefixes our metrics, so must be fixed rather than per-outbox; desc identifies us in logs
+	name       string
+	desc       string
+	pub        publisher
+	queues     map[string][]*outboxMsg
+	pending    int
+	maxPending int
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	published  uint64
+	failed     uint64
+	discarded  uint64
+}
+
+func newOutbox(pub publisher, attempts int, backoff, maxBackoff time.Duration) *outbox {
+	return &outbox{
+		name:       "outbox",
+		desc:       "outbox",
+		pub:        pub,
+		queues:     make(map[string][]*outboxMsg),
+		maxPending: outboxMaxPending,
+		attempts:   attempts,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+	}
+}
+
+// enqueue adds a message to the back of the queue for key, dropping it if the outbox is full
+func (o *outbox) enqueue(key, topic string, payload proto.Message) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.pending >= o.maxPending {
+		o.failed++
+		log.Errorf("[Discovery] %v full with %v pending, dropping %v for %v", o.desc, o.pending, topic, key)
+		inst.Counter(1.0, o.name+".failed", 1)
+		return
+	}
+
+	// a queue only exists whilst someone is draining it
+	q, draining := o.queues[key]
+	o.queues[key] = append(q, &outboxMsg{topic: topic, payload: payload})
+	o.pending++
+	inst.Gauge(1.0, o.name+".pending", o.pending)
+
+	if !draining {
+		go o.drain(key)
+	}
+}
+
+// drain sends messages for key, in order, until its queue is empty
+func (o *outbox) drain(key string) {
+	for {
+		o.Lock()
+		q := o.queues[key]
+		if len(q) == 0 {
+			delete(o.queues, key)
+			o.Unlock()
+			return
+		}
+		msg := q[0]
+		o.Unlock()
+
+		err := o.send(key, msg)
+
+		o.Lock()
+		o.queues[key] = o.queues[key][1:]
+		o.pending--
+		switch err {
+		case nil:
+			o.published++
+		case errDiscard:
+			o.discarded++
+		default:
+			o.failed++
+		}
+		inst.Gauge(1.0, o.name+".pending", o.pending)
+		o.Unlock()
+	}
+}
+
+// send publishes a message, retrying with backoff, returning nil once it is published, errDiscard if
+// the publisher no longer wants it, or the last error if we gave up
+func (o *outbox) send(key string, msg *outboxMsg) error {
+	delay := o.backoff
+	for attempt := 1; ; attempt++ {
+		err := o.pub.publish(msg.topic, msg.payload)
+		if err == nil {
+			inst.Counter(1.0, o.name+".published", 1)
+			return nil
+		}
+		if err == errDiscard {
+			log.Debugf("[Discovery] %v discarding %v for %v", o.desc, msg.topic, key)
+			inst.Counter(1.0, o.name+".discarded", 1)
+			return err
+		}
+		if attempt >= o.attempts {
+			log.Errorf("[Discovery] %v giving up publishing %v for %v after %v attempts: %v", o.desc, msg.topic, key, attempt, err)
+			inst.Counter(1.0, o.name+".failed", 1)
+			return err
+		}
+
+		log.Warnf("[Discovery] %v failed to publish %v for %v (attempt %v): %v -- retrying in %v", o.desc, msg.topic, key, attempt, err, delay)
+		inst.Counter(1.0, o.name+".retried", 1)
+		time.Sleep(delay)
+		if delay *= 2; delay > o.maxBackoff {
+			delay = o.maxBackoff
+		}
+	}
+}
+
+// stats returns how many messages are waiting to be sent, have been sent, and have been given up on
+func (o *outbox) stats() (pending int, published, failed uint64) {
+	o.Lock()
+	defer o.Unlock()
+	return o.pending, o.published, o.failed
+}
+
+// discards returns how many messages the publisher no longer wanted
+func (o *outbox) discards() uint64 {
+	o.Lock()
+	defer o.Unlock()
+	return o.discarded
+}
@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	serviceup "github.com/HailoOSS/discovery-service/proto/serviceup"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// fakePublisher records what it publishes, failing whenever fail says so
+type fakePublisher struct {
+	sync.Mutex
+	calls     map[string]int
+	published map[string][]uint64
+	fail      func(id string, call int) bool
+}
+
+func newFakePublisher(fail func(id string, call int) bool) *fakePublisher {
+	return &fakePublisher{
+		calls:     make(map[string]int),
+		published: make(map[string][]uint64),
+		fail:      fail,
+	}
+}
+
+func (p *fakePublisher) publish(topic string, payload proto.Message) error {
+	req := payload.(*serviceup.Request)
+	id := req.GetInstanceId()
+
+	p.Lock()
+	defer p.Unlock()
+	p.calls[id]++
+	if p.fail(id, p.calls[id]) {
+		return fmt.Errorf("Failed to publish call %v for %v", p.calls[id], id)
+	}
+	p.published[id] = append(p.published[id], req.GetServiceVersion())
+	return nil
+}
+
+func enqueueVersions(o *outbox, id string, n int) {
+	for v := 1; v <= n; v++ {
+		o.enqueue(id, serviceUpTopic, &serviceup.Request{
+			InstanceId:     proto.String(id),
+			ServiceVersion: proto.Uint64(uint64(v)),
+		})
+	}
+}
+
+func waitForDrain(t *testing.T, o *outbox) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if pending, _, _ := o.stats(); pending == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for outbox to drain")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOutboxPreservesOrderPerInstance(t *testing.T) {
+	// every third call fails, so most messages need retrying at least once
+	pub := newFakePublisher(func(id string, call int) bool {
+		return call%3 == 0
+	})
+	o := newOutbox(pub, 5, time.Millisecond, 5*time.Millisecond)
+
+	ids := []string{"inst-a", "inst-b", "inst-c"}
+	for _, id := range ids {
+		enqueueVersions(o, id, 50)
+	}
+	waitForDrain(t, o)
+
+	for _, id := range ids {
+		got := pub.published[id]
+		if len(got) != 50 {
+			t.Fatalf("Expected 50 publications for %v, got %v", id, len(got))
+		}
+		for i, v := range got {
+			if v != uint64(i+1) {
+				t.Fatalf("Publications for %v out of order at %v: %v", id, i, got)
+			}
+		}
+	}
+
+	if _, published, failed := o.stats(); published != 150 || failed != 0 {
+		t.Errorf("Expected 150 published and 0 failed, got %v and %v", published, failed)
+	}
+}
+
+func TestOutboxGivesUpAfterAttempts(t *testing.T) {
+	// the second message for inst-a never gets through
+	pub := newFakePublisher(func(id string, call int) bool {
+		return id == "inst-a" && call >= 2 && call <= 4
+	})
+	o := newOutbox(pub, 3, time.Millisecond, time.Millisecond)
+
+	enqueueVersions(o, "inst-a", 3)
+	enqueueVersions(o, "inst-b", 3)
+	waitForDrain(t, o)
+
+	if got := pub.published["inst-a"]; len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("Expected versions [1 3] for inst-a, got %v", got)
+	}
+	if got := pub.published["inst-b"]; len(got) != 3 {
+		t.Errorf("Expected 3 publications for inst-b, got %v", got)
+	}
+	if _, published, failed := o.stats(); published != 5 || failed != 1 {
+		t.Errorf("Expected 5 published and 1 failed, got %v and %v", published, failed)
+	}
+}
+
+func TestOutboxDropsWhenFull(t *testing.T) {
+	block := make(chan struct{})
+	pub := newFakePublisher(func(id string, call int) bool {
+		<-block
+		return false
+	})
+	o := newOutbox(pub, 1, time.Millisecond, time.Millisecond)
+	o.maxPending = 2
+
+	enqueueVersions(o, "inst-a", 3)
+	if pending, _, failed := o.stats(); pending != 2 || failed != 1 {
+		t.Errorf("Expected 2 pending and 1 dropped, got %v and %v", pending, failed)
+	}
+
+	close(block)
+	waitForDrain(t, o)
+	if got := pub.published["inst-a"]; len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("Expected versions [1 2] for inst-a, got %v", got)
+	}
+}
+
+func TestOutboxDiscards(t *testing.T) {
+	pub := &discardPublisher{}
+	o := newOutbox(pub, 3, time.Millisecond, time.Millisecond)
+
+	enqueueVersions(o, "inst-a", 2)
+	waitForDrain(t, o)
+
+	if pub.calls != 2 {
+		t.Errorf("Expected each discarded message to be tried once, got %v calls", pub.calls)
+	}
+	if _, published, failed := o.stats(); published != 0 || failed != 0 {
+		t.Errorf("Expected discarded messages to be neither published nor failed, got %v and %v", published, failed)
+	}
+	if discarded := o.discards(); discarded != 2 {
+		t.Errorf("Expected 2 discarded, got %v", discarded)
+	}
+}
+
+// discardPublisher no longer wants anything it is asked to publish
+type discardPublisher struct {
+	sync.Mutex
+	calls int
+}
+
+func (p *discardPublisher) publish(topic string, payload proto.Message) error {
+	p.Lock()
+	defer p.Unlock()
+	p.calls++
+	return errDiscard
+}
@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"sort"
+)
+
+// Team is everything run by a single team, grouped by who to contact
+type Team struct {
+	Name     string
+	Contacts []*Contact
+}
+
+// Contact is someone responsible for a set of services
+type Contact struct {
+	Email    string
+	Mobile   string
+	Services []*OwnedService
+}
+
+// OwnedService is a service that a contact is responsible for
+type OwnedService struct {
+	Name      string
+	Versions  []uint64
+	Instances int
+}
+
+// Owners groups instances by owning team and then contact, everything ordered by name
+func (is Instances) Owners() []*Team {
+	type contactKey struct {
+		team, email, mobile string
+	}
+	type serviceKey struct {
+		contactKey
+		service string
+	}
+
+	teams := make(map[string]*Team)
+	contacts := make(map[contactKey]*Contact)
+	services := make(map[serviceKey]*OwnedService)
+	for _, inst := range is {
+		team, ok := teams[inst.OwnerTeam]
+		if !ok {
+			team = &Team{Name: inst.OwnerTeam}
+			teams[inst.OwnerTeam] = team
+		}
+
+		ck := contactKey{inst.OwnerTeam, inst.OwnerEmail, inst.OwnerMobile}
+		contact, ok := contacts[ck]
+		if !ok {
+			contact = &Contact{Email: inst.OwnerEmail, Mobile: inst.OwnerMobile}
+			contacts[ck] = contact
+			team.Contacts = append(team.Contacts, contact)
+		}
+
+		sk := serviceKey{ck, inst.Name}
+		svc, ok := services[sk]
+		if !ok {
+			svc = &OwnedService{Name: inst.Name}
+			services[sk] = svc
+			contact.Services = append(contact.Services, svc)
+		}
+		svc.Instances++
+		if !hasVersion(svc.Versions, inst.Version) {
+			svc.Versions = append(svc.Versions, inst.Version)
+		}
+	}
+
+	ret := make([]*Team, 0, len(teams))
+	for _, team := range teams {
+		sort.Sort(byContact(team.Contacts))
+		for _, contact := range team.Contacts {
+			sort.Sort(byServiceName(contact.Services))
+			for _, svc := range contact.Services {
+				sort.Sort(versions(svc.Versions))
+			}
+		}
+		ret = append(ret, team)
+	}
+	sort.Sort(byTeamName(ret))
+
+	return ret
+}
+
+func hasVersion(vs []uint64, v uint64) bool {
+	for _, existing := range vs {
+		if existing == v {
+			return true
+		}
+	}
+	return false
+}
+
+type byTeamName []*Team
+
+func (t byTeamName) Len() int           { return len(t) }
+func (t byTeamName) Less(i, j int) bool { return t[i].Name < t[j].Name }
+func (t byTeamName) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+type byContact []*Contact
+
+func (c byContact) Len() int      { return len(c) }
+func (c byContact) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byContact) Less(i, j int) bool {
+	if c[i].Email != c[j].Email {
+		return c[i].Email < c[j].Email
+	}
+	return c[i].Mobile < c[j].Mobile
+}
+
+type byServiceName []*OwnedService
+
+func (s byServiceName) Len() int           { return len(s) }
+func (s byServiceName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s byServiceName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
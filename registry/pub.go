@@ -1,53 +1,94 @@
 package registry
 
 import (
-	log "github.com/cihub/seelog"
+	endpointdown "github.com/HailoOSS/discovery-service/proto/endpointdown"
+	endpointup "github.com/HailoOSS/discovery-service/proto/endpointup"
 	servicedown "github.com/HailoOSS/discovery-service/proto/servicedown"
 	serviceup "github.com/HailoOSS/discovery-service/proto/serviceup"
 	"github.com/HailoOSS/platform/client"
 	"github.com/HailoOSS/protobuf/proto"
 )
 
-// pubServiceUp transmits via the platform the fact that we've come up
-func pubServiceUp(inst *Instance) {
-	pub, err := client.NewPublication(
-		"com.HailoOSS.kernel.discovery.serviceup",
-		&serviceup.Request{
-			InstanceId:     proto.String(inst.Id),
-			Hostname:       proto.String(inst.Hostname),
-			ServiceName:    proto.String(inst.Name),
-			ServiceVersion: proto.Uint64(inst.Version),
-			AzName:         proto.String(inst.AzName),
-			EndpointName:   proto.String(""),
-			SubTopic:       inst.GetSubTopics(),
-		},
-	)
+const (
+	serviceUpTopic    = "com.HailoOSS.kernel.discovery.serviceup"
+	serviceDownTopic  = "com.HailoOSS.kernel.discovery.servicedown"
+	endpointUpTopic   = "com.HailoOSS.kernel.discovery.endpointup"
+	endpointDownTopic = "com.HailoOSS.kernel.discovery.endpointdown"
+)
+
+// platformPublisher publishes messages via the platform
+type platformPublisher struct{}
+
+func (platformPublisher) publish(topic string, payload proto.Message) error {
+	pub, err := client.NewPublication(topic, payload)
 	if err != nil {
-		log.Warnf("[Discovery] Failed to create serviceup message: %v", err)
-	} else {
-		err := client.AsyncTopic(pub)
-		if err != nil {
-			log.Warnf("[Discovery] Failed to publish serviceup: %v", err)
-		}
+		return err
 	}
+	return client.AsyncTopic(pub)
 }
 
-// pubServiceDown transmits via the platform the fact that we've gone down
-func pubServiceDown(inst *Instance) {
-	pub, err := client.NewPublication("com.HailoOSS.kernel.discovery.servicedown", &servicedown.Request{
+// pubServiceUp transmits via the platform, and any interested webhooks, the fact that we've come up
+func pubServiceUp(inst *Instance) {
+	req := &serviceup.Request{
 		InstanceId:     proto.String(inst.Id),
 		Hostname:       proto.String(inst.Hostname),
 		ServiceName:    proto.String(inst.Name),
 		ServiceVersion: proto.Uint64(inst.Version),
 		AzName:         proto.String(inst.AzName),
 		EndpointName:   proto.String(""),
-	})
-	if err != nil {
-		log.Warn("[Discovery] Failed to create servicedown message ", err)
-	} else {
-		err := client.AsyncTopic(pub)
-		if err != nil {
-			log.Warn("[Discovery] Failed to publish servicedown ", err)
-		}
+		SubTopic:       inst.GetSubTopics(),
 	}
+	publications.enqueue(inst.Id, serviceUpTopic, req)
+	hooks.notify(inst.Name, inst.Id, serviceUpTopic, req)
+}
+
+var reasonToProto = map[Reason]servicedown.Request_Reason{
+	ReasonUnknown:          servicedown.Request_UNKNOWN,
+	ReasonUnregister:       servicedown.Request_UNREGISTER,
+	ReasonHeartbeatTimeout: servicedown.Request_HEARTBEAT_TIMEOUT,
+	ReasonSessionExpiry:    servicedown.Request_SESSION_EXPIRY,
+	ReasonDrained:          servicedown.Request_DRAINED,
+	ReasonEviction:         servicedown.Request_ADMIN_EVICTION,
+}
+
+// pubServiceDown transmits via the platform, and any interested webhooks, the fact that we've gone down, and why
+func pubServiceDown(inst *Instance, t *tombstone) {
+	req := &servicedown.Request{
+		InstanceId:     proto.String(inst.Id),
+		Hostname:       proto.String(inst.Hostname),
+		ServiceName:    proto.String(inst.Name),
+		ServiceVersion: proto.Uint64(inst.Version),
+		AzName:         proto.String(inst.AzName),
+		EndpointName:   proto.String(""),
+		Reason:         reasonToProto[t.Reason].Enum(),
+	}
+	if !t.LastHeartbeat.IsZero() {
+		req.LastHeartbeat = proto.Int64(t.LastHeartbeat.Unix())
+	}
+	publications.enqueue(inst.Id, serviceDownTopic, req)
+	hooks.notify(inst.Name, inst.Id, serviceDownTopic, req)
+}
+
+// pubEndpointUp transmits via the platform the fact that an endpoint is newly available within the region
+func pubEndpointUp(inst *Instance, ep *Endpoint) {
+	publications.enqueue(inst.Name+"."+ep.Name, endpointUpTopic, &endpointup.Request{
+		ServiceName:    proto.String(inst.Name),
+		ServiceVersion: proto.Uint64(inst.Version),
+		EndpointName:   proto.String(ep.Name),
+		Subscribe:      proto.String(ep.Subscribe),
+		Mean:           proto.Uint32(ep.Sla.Mean),
+		Upper95:        proto.Uint32(ep.Sla.Upper95),
+	})
+}
+
+// pubEndpointDown transmits via the platform the fact that an endpoint is no longer available within the region
+func pubEndpointDown(inst *Instance, ep *Endpoint) {
+	publications.enqueue(inst.Name+"."+ep.Name, endpointDownTopic, &endpointdown.Request{
+		ServiceName:    proto.String(inst.Name),
+		ServiceVersion: proto.Uint64(inst.Version),
+		EndpointName:   proto.String(ep.Name),
+		Subscribe:      proto.String(ep.Subscribe),
+		Mean:           proto.Uint32(ep.Sla.Mean),
+		Upper95:        proto.Uint32(ep.Sla.Upper95),
+	})
 }
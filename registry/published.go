@@ -0,0 +1,210 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const (
+	publishedNode = "/discovery-service-published"
+
+	// publishedWindow is how far back a new leader looks for events that went unpublished
+	publishedWindow = 5 * time.Minute
+	// publishedMax is the most events we remember publishing, to keep the node well within ZK's limits
+	publishedMax = 5000
+)
+
+// publishedRecord is what the leader stores in publishedNode
+type publishedRecord struct {
+	// Events is when each recently published event was published, by eventKey
+	Events map[string]time.Time
+}
+
+// publishedStore holds the published log
+type publishedStore interface {
+	// get returns the stored log, or gozk.ErrNoNode if there is none
+	get() ([]byte, error)
+	put(b []byte) error
+}
+
+// zkPublishedStore holds the published log in publishedNode
+type zkPublishedStore struct{}
+
+func (zkPublishedStore) get() ([]byte, error) {
+	b, _, err := zk.Get(publishedNode)
+	return b, err
+}
+
+func (zkPublishedStore) put(b []byte) error {
+	_, err := zk.Set(publishedNode, b, -1)
+	if err == gozk.ErrNoNode {
+		_, err = zk.Create(publishedNode, b, 0, acls.forPath(publishedNode))
+		if err == gozk.ErrNodeExists {
+			_, err = zk.Set(publishedNode, b, -1)
+		}
+	}
+	return err
+}
+
+// publishedLog records in ZK which serviceup/servicedown events the leader has published. Events seen
+// whilst there is no leader aren't published by anyone - eg: when the leader's host dies, its instances
+// go with its session at about the time a new leader is elected - so a new leader publishes whatever
+// it has journalled recently that isn't in the log.
+type publishedLog struct {
+	sync.Mutex
+	store  publishedStore
+	events map[string]time.Time
+}
+
+var published = newPublishedLog(zkPublishedStore{})
+
+func newPublishedLog(store publishedStore) *publishedLog {
+	return &publishedLog{
+		store:  store,
+		events: make(map[string]time.Time),
+	}
+}
+
+// eventKey identifies an event the same way on every discovery node
+func eventKey(e *Event) string {
+	return fmt.Sprintf("%v:%v:%v", e.Type, e.InstanceId, e.Registered.UnixNano())
+}
+
+// takeLead publishes any events that went unpublished whilst there was no leader, then calls become
+// to make us leader. Holding the region's announcing lock throughout means nothing can be announced in
+// between, and so either missed or published twice, without holding up syncs: whatever they queue in
+// the meantime is announced as leader once we're done.
+func takeLead(become func()) {
+	region.announcing.Lock()
+	if err := published.catchUp(region); err != nil {
+		log.Warnf("[Discovery] Failed to publish events missed whilst there was no leader: %v", err)
+	}
+	become()
+	region.announcing.Unlock()
+
+	region.flush()
+}
+
+// catchUp publishes events we have journalled within publishedWindow that the log doesn't have; the
+// caller must hold the region's announcing lock
+func (p *publishedLog) catchUp(r *regionReg) error {
+	p.Lock()
+	defer p.Unlock()
+
+	b, err := p.store.get()
+	if err == gozk.ErrNoNode {
+		// nobody has led with a log before, so we can't tell what went unpublished
+		p.events = make(map[string]time.Time)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	rec := &publishedRecord{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, rec); err != nil {
+			return fmt.Errorf("Failed to unmarshal published events: %v", err)
+		}
+	}
+	p.events = rec.Events
+	if p.events == nil {
+		p.events = make(map[string]time.Time)
+	}
+
+	events, _, err := journal.replay(0, time.Now().Add(-publishedWindow), MaxReplay)
+	if err != nil {
+		return err
+	}
+	missed := 0
+	for _, e := range events {
+		if (e.Type != EventUp && e.Type != EventDown) || e.Registered.IsZero() {
+			continue
+		}
+		if _, ok := p.events[eventKey(e)]; ok {
+			continue
+		}
+
+		log.Infof("[Discovery] Publishing %v of %v, missed whilst there was no leader", e.Type, e.InstanceId)
+		i := r.singleInstance(e.InstanceId)
+		if i == nil || !i.Registered.Equal(e.Registered) {
+			i = &Instance{
+				Id:         e.InstanceId,
+				Hostname:   e.Hostname,
+				Name:       e.ServiceName,
+				Version:    e.ServiceVersion,
+				AzName:     e.AzName,
+				Registered: e.Registered,
+			}
+		}
+		if e.Type == EventUp {
+			pubServiceUp(i)
+		} else {
+			pubServiceDown(i, &tombstone{Reason: e.Reason})
+		}
+		p.events[eventKey(e)] = time.Now()
+		missed++
+	}
+	if missed == 0 {
+		return nil
+	}
+	return p.save()
+}
+
+// record notes that we have published some events, with a single write however many there are
+func (p *publishedLog) record(events ...*Event) {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	for _, e := range events {
+		p.events[eventKey(e)] = now
+	}
+	if err := p.save(); err != nil {
+		log.Warnf("[Discovery] Failed to record publishing %v events: %v", len(events), err)
+	}
+}
+
+// save trims events that have fallen out of the window (or beyond publishedMax) and writes the rest to
+// ZK; the caller must hold the lock
+func (p *publishedLog) save() error {
+	cutOff := time.Now().Add(-publishedWindow)
+	keys := make([]string, 0, len(p.events))
+	for k, t := range p.events {
+		if t.Before(cutOff) {
+			delete(p.events, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) > publishedMax {
+		sort.Sort(byPublished{keys: keys, events: p.events})
+		for _, k := range keys[:len(keys)-publishedMax] {
+			delete(p.events, k)
+		}
+	}
+
+	b, err := json.Marshal(&publishedRecord{Events: p.events})
+	if err != nil {
+		return fmt.Errorf("Failed to marshal published events: %v", err)
+	}
+	return p.store.put(b)
+}
+
+// byPublished orders event keys by when they were published, oldest first
+type byPublished struct {
+	keys   []string
+	events map[string]time.Time
+}
+
+func (s byPublished) Len() int      { return len(s.keys) }
+func (s byPublished) Swap(i, j int) { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s byPublished) Less(i, j int) bool {
+	return s.events[s.keys[i]].Before(s.events[s.keys[j]])
+}
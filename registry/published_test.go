@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCatchUpPublishesMissedEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery-journal")
+	if err != nil {
+		t.Fatalf("Failed to create journal dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(j *eventJournal, o *outbox, h *webhookReg) {
+		journal, publications, hooks = j, o, h
+	}(journal, publications, hooks)
+
+	if journal, err = newEventJournal(dir); err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	pub := &orderPublisher{}
+	publications = newOutbox(pub, 1, time.Millisecond, time.Millisecond)
+	hooks = &webhookReg{hooks: make(map[string]*registeredHook)}
+
+	registered := time.Now().Add(-time.Minute)
+	up := &Event{Type: EventUp, InstanceId: "instance-1", Registered: registered}
+	down := &Event{Type: EventDown, InstanceId: "instance-1", Registered: registered, Reason: ReasonSessionExpiry}
+	legacy := &Event{Type: EventDown, InstanceId: "instance-2"}
+	for _, e := range []*Event{up, down, legacy} {
+		journal.append(e)
+	}
+
+	// our predecessor published the serviceup, but died with its session before the servicedown
+	b, _ := json.Marshal(&publishedRecord{Events: map[string]time.Time{eventKey(up): time.Now()}})
+	store := &memPublishedStore{b: b}
+	p := newPublishedLog(store)
+	if err := p.catchUp(emptyRegionReg("test")); err != nil {
+		t.Fatalf("Failed to catch up: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pub.published()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	expectOrder(t, "published", []string{serviceDownTopic + ":instance-1:SESSION_EXPIRY"}, pub.published())
+
+	rec := &publishedRecord{}
+	if err := json.Unmarshal(store.b, rec); err != nil {
+		t.Fatalf("Failed to unmarshal published log: %v", err)
+	}
+	if _, ok := rec.Events[eventKey(down)]; !ok {
+		t.Errorf("Expected missed event to be recorded as published, got %v", rec.Events)
+	}
+
+	// a second takeover has nothing left to publish
+	if err := newPublishedLog(store).catchUp(emptyRegionReg("test")); err != nil {
+		t.Fatalf("Failed to catch up: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := len(pub.published()); n != 1 {
+		t.Errorf("Expected nothing more to be published, got %v", pub.published())
+	}
+}
+
+func TestCatchUpWithoutLog(t *testing.T) {
+	// without a log, we can't tell what was published by a leader running an older version
+	p := newPublishedLog(&memPublishedStore{})
+	if err := p.catchUp(emptyRegionReg("test")); err != nil {
+		t.Errorf("Expected no error catching up without a log, got %v", err)
+	}
+}
@@ -1,103 +1,581 @@
 package registry
 
 import (
-	"encoding/json"
+	"fmt"
 	log "github.com/cihub/seelog"
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
 	zk "github.com/HailoOSS/service/zookeeper"
 	"os"
 	"sync"
 	"time"
 )
 
+// syncInterval is how often we reconcile our copy of a region with ZK, in case we've missed changes
 const syncInterval = time.Minute * 5
 
+// zkConn is the subset of ZK operations needed to sync a region, satisfied both by the
+// platform's shared connection and by a direct connection to a peer region's ensemble
+type zkConn interface {
+	Children(path string) ([]string, *gozk.Stat, error)
+	ChildrenW(path string) ([]string, *gozk.Stat, <-chan gozk.Event, error)
+	Get(path string) ([]byte, *gozk.Stat, error)
+	GetW(path string) ([]byte, *gozk.Stat, <-chan gozk.Event, error)
+}
+
+// platformZk satisfies zkConn using the platform's shared ZK connection
+type platformZk struct{}
+
+func (platformZk) Children(path string) ([]string, *gozk.Stat, error) {
+	return zk.Children(path)
+}
+
+func (platformZk) ChildrenW(path string) ([]string, *gozk.Stat, <-chan gozk.Event, error) {
+	return zk.ChildrenW(path)
+}
+
+func (platformZk) Get(path string) ([]byte, *gozk.Stat, error) {
+	return zk.Get(path)
+}
+
+func (platformZk) GetW(path string) ([]byte, *gozk.Stat, <-chan gozk.Event, error) {
+	return zk.GetW(path)
+}
+
 type regionReg struct {
 	sync.RWMutex
+	name      string
 	instances map[string]*Instance
+	// endpoints counts how many instances provide each endpoint of each service
+	endpoints map[endpointKey]int
+	// mzxids holds the ZK transaction that last modified each instance's document, so we never
+	// replace our copy with an older one
+	mzxids map[string]int64
+	// paths is where each instance is stored
+	paths map[string]string
+	// watching is which instances we have a data watch on, and via which connection
+	watching map[string]zkConn
+	// services is which service nodes we are watching the children of (in the hierarchical layout),
+	// and via which connection
+	services map[string]zkConn
+	// quiet is the parent nodes whose next sync we shouldn't announce, since it is our first look at
+	// instances that were already there
+	quiet map[string]bool
+	// publish indicates we should announce instances coming and going (whilst leader)
+	publish bool
+	// syncedRoot is set once we've synced rootNode, and synced once we've also synced every service
+	// node found beneath it whilst we were yet to sync
+	syncedRoot bool
+	synced     bool
+	// loaded is instances restored from a snapshot taken at loadedAt, which we serve until we've synced
+	loaded   map[string]*Instance
+	loadedAt time.Time
+	// revision is bumped whenever instances change, so anything derived from them can be cached
+	revision uint64
+	// pending is changes queued whilst holding the lock, for flush to announce once it's released
+	pending []*announcement
+	// announcing is held whilst flushing, so changes are announced one at a time in the order queued
+	announcing sync.Mutex
 }
 
-func newRegionReg() *regionReg {
-	r := &regionReg{
-		instances: make(map[string]*Instance),
-	}
+// announcement is a set of changes to a region, queued whilst holding the lock and announced after
+type announcement struct {
+	added, removed     Instances
+	epAdded, epRemoved []*endpointChange
+	// journalled is further events which are only journalled
+	journalled []*Event
+}
+
+func newRegionReg(name string) *regionReg {
+	r := emptyRegionReg(name)
+	r.publish = true
+	snapshots.restore(r)
 
 	go r.syncer()
+	go snapshots.writer(r)
 
 	return r
 }
 
+// emptyRegionReg returns a region that we have yet to sync
+func emptyRegionReg(name string) *regionReg {
+	return &regionReg{
+		name:      name,
+		instances: make(map[string]*Instance),
+		endpoints: make(map[endpointKey]int),
+		mzxids:    make(map[string]int64),
+		paths:     make(map[string]string),
+		watching:  make(map[string]zkConn),
+		services:  make(map[string]zkConn),
+		quiet:     make(map[string]bool),
+	}
+}
+
 // syncer will continually sync with ZK, or quit on failure
 func (r *regionReg) syncer() {
 	log.Debug("[Discovery] Launching syncer...")
+	if err := r.watch(platformZk{}); err != nil {
+		log.Errorf("[Discovery] %v, so exiting", err)
+	}
+
+	log.Infof("[Discovery] Quitting syncer and exiting")
+	os.Exit(1)
+}
+
+// watch will continually sync with ZK via conn, returning on failure
+func (r *regionReg) watch(conn zkConn) error {
+	// errors from watching individual services, which only need to be reported whilst we're watching
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go r.reconciler(conn, errs, done)
 	for {
-		instanceIds, _, watch, err := zk.ChildrenW(rootNode)
+		children, _, watch, err := conn.ChildrenW(rootNode)
 		if err != nil {
-			log.Errorf("[Discovery] Failed to read children, so exiting: %v", err)
-			break
+			return fmt.Errorf("Failed to read children: %v", err)
 		}
 
-		if err := r.sync(instanceIds); err != nil {
-			log.Errorf("[Discovery] Failed to sync instances, so exiting: %v", err)
-			break
+		services, err := r.sync(conn, rootNode, children)
+		if err != nil {
+			return fmt.Errorf("Failed to sync instances: %v", err)
+		}
+		for _, service := range services {
+			go r.watchService(conn, service, errs)
 		}
 
 		// @todo not entirely sure what happens when zk conn.Close() happens - hopefully sender closes channel
-		e := <-watch
-		log.Debugf("[Discovery] Watch triggered for event %v", e)
+		select {
+		case e := <-watch:
+			log.Debugf("[Discovery] Watch triggered for event %v", e)
+		case err := <-errs:
+			return err
+		}
 	}
+}
 
-	log.Infof("[Discovery] Quitting syncer and exiting")
-	os.Exit(1)
+// reconciler reconciles the region every syncInterval until done is closed. Reconciling rereads the
+// whole region, so it runs here rather than holding up the watch on rootNode, and one at a time, with
+// any ticks missed whilst reconciling dropped.
+func (r *regionReg) reconciler(conn zkConn, errs chan<- error, done <-chan struct{}) {
+	tick := time.NewTicker(syncInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+		}
+
+		services, err := r.reconcile(conn)
+		if err != nil {
+			log.Warnf("[Discovery] Failed to reconcile region %v: %v", r.name, err)
+		}
+		for _, service := range services {
+			go r.watchService(conn, service, errs)
+		}
+	}
 }
 
-// sync
-func (r *regionReg) sync(instanceIds []string) error {
+// watchService continually syncs the instances of a service in the hierarchical layout, until its
+// service node is reaped or we can no longer read it, in which case the error is sent to errs
+func (r *regionReg) watchService(conn zkConn, service string, errs chan<- error) {
+	defer func() {
+		r.Lock()
+		if r.services[service] == conn {
+			delete(r.services, service)
+		}
+		r.Unlock()
+	}()
+
+	path := zkPathForService(service)
+	for {
+		ids, _, watch, err := conn.ChildrenW(path)
+		if err == gozk.ErrNoNode {
+			// reaped, which only happens once empty, but make sure we don't hang on to anything
+			r.sync(conn, path, nil)
+			return
+		}
+		if err == nil {
+			_, err = r.sync(conn, path, ids)
+		}
+		if err != nil {
+			select {
+			case errs <- fmt.Errorf("Failed to sync instances of %v: %v", service, err):
+			default:
+			}
+			return
+		}
+
+		e := <-watch
+		log.Debugf("[Discovery] Watch triggered on %v for event %v", service, e)
+	}
+}
+
+// sync brings our copy of the region in line with the children of a parent node (either rootNode or
+// a service node), reading the document of each new instance and watching it for changes. Children
+// of rootNode may be service nodes, which are returned for the caller to watch.
+func (r *regionReg) sync(conn zkConn, parent string, children []string) ([]string, error) {
+	defer r.flush()
 	r.Lock()
 	defer r.Unlock()
+	return r.apply(conn, parent, children)
+}
 
+// apply does the work of sync, queueing the changes for the caller to flush; the caller must hold the lock
+func (r *regionReg) apply(conn zkConn, parent string, children []string) ([]string, error) {
+	var (
+		added, removed     Instances
+		epAdded, epRemoved []*endpointChange
+		services           []string
+	)
 	seen := map[string]bool{}
-	for _, id := range instanceIds {
-		// do we know about this? assumption: registry never changes for an instance ID
-		if _, ok := r.instances[id]; !ok {
-			// look up and add this instance
-			b, _, err := zk.Get(zkPathForInstance(id))
-			if err != nil {
-				return err
-			}
-			// unmarshal the document
-			instance := &Instance{}
-			if err := json.Unmarshal(b, instance); err != nil {
-				return err
+	for _, id := range children {
+		if parent == rootNode && r.services[id] == conn {
+			continue
+		}
+		path := parent + "/" + id
+		_, known := r.instances[id]
+		if known && r.paths[id] != path {
+			log.Warnf("[Discovery] Ignoring %v, since instance is already registered at %v", path, r.paths[id])
+			continue
+		}
+		if known && r.watching[id] == conn {
+			seen[id] = true
+			continue
+		}
+
+		// look up this instance, watching for changes
+		b, stat, watch, err := conn.GetW(path)
+		if err == gozk.ErrNoNode {
+			// gone already
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if parent == rootNode && isServiceNode(stat) {
+			r.services[id] = conn
+			services = append(services, id)
+			if !r.synced {
+				r.quiet[zkPathForService(id)] = true
 			}
-			r.instances[id] = instance
+			continue
 		}
 		seen[id] = true
+		r.watching[id] = conn
+		go r.watchInstance(conn, id, path, watch)
+
+		// unmarshal the document
+		instance, err := decodeInstance(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if known {
+			// we lost our watch, so it may have changed in the meantime
+			r.replace(instance, stat)
+			continue
+		}
+		instance.Region = r.name
+		instance.Registered = time.Unix(0, stat.Ctime*int64(time.Millisecond))
+		r.instances[id] = instance
+		r.mzxids[id] = stat.Mzxid
+		r.paths[id] = path
+		added = append(added, instance)
+		epAdded = append(epAdded, r.countEndpoints(instance, 1)...)
 	}
 
 	// remove any not seen
 	for id, _ := range r.instances {
-		if !seen[id] {
+		if !seen[id] && parentOf(r.paths[id]) == parent {
 			// strip
+			removed = append(removed, r.instances[id])
+			epRemoved = append(epRemoved, r.countEndpoints(r.instances[id], -1)...)
 			delete(r.instances, id)
+			delete(r.mzxids, id)
+			delete(r.paths, id)
 		}
 	}
 
-	return nil
+	if len(added) > 0 || len(removed) > 0 {
+		r.revision++
+	}
+	if !r.quiet[parent] {
+		r.queue(&announcement{added: added, removed: removed, epAdded: epAdded, epRemoved: epRemoved})
+	}
+	delete(r.quiet, parent)
+	if parent == rootNode {
+		r.syncedRoot = true
+	}
+	if !r.synced && r.syncedRoot && len(r.quiet) == 0 {
+		// the quiet service nodes are exactly those we've yet to sync for the first time
+		r.synced = true
+		if r.loaded != nil {
+			log.Infof("[Discovery] Synced region %v, no longer serving snapshot", r.name)
+			r.loaded = nil
+			r.revision++
+		}
+	}
+
+	return services, nil
+}
+
+// watchInstance keeps our copy of an instance up to date as its document changes, until it goes
+// away or we lose the watch (eg: on disconnection), after which the next sync will read it afresh
+func (r *regionReg) watchInstance(conn zkConn, id, path string, watch <-chan gozk.Event) {
+	defer func() {
+		r.Lock()
+		if r.watching[id] == conn {
+			delete(r.watching, id)
+		}
+		r.Unlock()
+	}()
+
+	for {
+		e := <-watch
+		if e.Type != gozk.EventNodeDataChanged {
+			return
+		}
+
+		b, stat, w, err := conn.GetW(path)
+		if err != nil {
+			if err != gozk.ErrNoNode {
+				log.Warnf("[Discovery] Failed to read changed instance %v: %v", id, err)
+			}
+			return
+		}
+		watch = w
+
+		inst, err := decodeInstance(b)
+		if err != nil {
+			log.Warnf("[Discovery] Failed to unmarshal changed instance %v: %v", id, err)
+			continue
+		}
+		r.update(inst, stat)
+	}
+}
+
+// queue notes changes for flush to announce once the lock is released; the caller must hold the lock.
+// Our initial sync isn't announced since these instances aren't new, we just haven't seen them before.
+func (r *regionReg) queue(a *announcement) {
+	if !r.publish {
+		return
+	}
+	if !r.synced {
+		a.added, a.removed, a.epAdded, a.epRemoved = nil, nil, nil, nil
+	}
+	r.pending = append(r.pending, a)
+}
+
+// flush announces everything queued so far. It is called after releasing the lock, so the ZK round
+// trips involved (reading tombstones, publishing, recording what we've published) never hold up
+// readers or the next sync. Flushes happen one at a time and take everything queued, so changes are
+// always announced in the order they were queued, whoever flushes them.
+func (r *regionReg) flush() {
+	r.announcing.Lock()
+	defer r.announcing.Unlock()
+
+	r.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	publish := leader.isLeader()
+	var recorded []*Event
+	for _, a := range pending {
+		recorded = append(recorded, r.announce(a, publish)...)
+	}
+	if len(recorded) > 0 {
+		published.record(recorded...)
+	}
+}
+
+// announce journals and publishes serviceup/servicedown for instances that have come and gone,
+// returning the events published for the caller to record. Every discovery node sees the same
+// changes, however they happen (unregistering, heartbeat failure, session expiry), so all journal
+// them but only the leader publishes them to avoid duplicates.
+// Likewise we announce endpoints that are new to the region, or no longer provided by any instance.
+// Departures come first, since they can only have happened before the arrivals seen in the same sync.
+func (r *regionReg) announce(a *announcement, publish bool) []*Event {
+	var recorded []*Event
+	for _, inst := range a.removed {
+		t := tombstoneOf(inst.Id)
+		e := newEvent(EventDown, inst)
+		e.Reason = t.Reason
+		journal.append(e)
+		if publish {
+			pubServiceDown(inst, t)
+			recorded = append(recorded, e)
+		}
+	}
+	if publish {
+		for _, c := range a.epRemoved {
+			pubEndpointDown(c.inst, c.ep)
+		}
+	}
+	for _, inst := range a.added {
+		e := newEvent(EventUp, inst)
+		journal.append(e)
+		if publish {
+			pubServiceUp(inst)
+			recorded = append(recorded, e)
+		}
+	}
+	if publish {
+		for _, c := range a.epAdded {
+			pubEndpointUp(c.inst, c.ep)
+		}
+	}
+	for _, e := range a.journalled {
+		journal.append(e)
+	}
+	return recorded
+}
+
+// update replaces our copy of an instance we already know about, given the stat of the document it
+// was read from or written to
+func (r *regionReg) update(inst *Instance, stat *gozk.Stat) {
+	defer r.flush()
+	r.Lock()
+	defer r.Unlock()
+	r.replace(inst, stat)
+}
+
+// replace does the work of update, queueing the changes for the caller to flush; the caller must hold the lock
+func (r *regionReg) replace(inst *Instance, stat *gozk.Stat) {
+	old, ok := r.instances[inst.Id]
+	if !ok || stat.Mzxid <= r.mzxids[inst.Id] {
+		return
+	}
+
+	inst.Region = r.name
+	inst.Registered = time.Unix(0, stat.Ctime*int64(time.Millisecond))
+	r.instances[inst.Id] = inst
+	r.mzxids[inst.Id] = stat.Mzxid
+	r.revision++
+	a := &announcement{
+		epRemoved: r.countEndpoints(old, -1),
+		epAdded:   r.countEndpoints(inst, 1),
+	}
+	switch {
+	case inst.Drained && !old.Drained:
+		a.journalled = append(a.journalled, newEvent(EventDrained, inst))
+	case !sameMetadata(old, inst):
+		a.journalled = append(a.journalled, newEvent(EventUpdated, inst))
+	}
+	r.queue(a)
+}
+
+// sameMetadata returns whether two copies of an instance's document are the same
+func sameMetadata(a, b *Instance) bool {
+	return a.Weight == b.Weight && a.Drained == b.Drained && sameLabels(a.Labels, b.Labels) && sameRegistration(a, b)
+}
+
+// endpointKey identifies an endpoint of a service, regardless of version
+type endpointKey struct {
+	service  string
+	endpoint string
+}
+
+// endpointChange is an endpoint that has appeared in or disappeared from the region, along with
+// the instance responsible
+type endpointChange struct {
+	inst *Instance
+	ep   *Endpoint
+}
+
+// countEndpoints adjusts how many instances provide each of an instance's endpoints by delta,
+// returning those that have just appeared in (or disappeared from) the region as a result. Only
+// regions we announce keep count, so peer regions never touch their endpoints.
+func (r *regionReg) countEndpoints(inst *Instance, delta int) []*endpointChange {
+	if !r.publish {
+		return nil
+	}
+	var changes []*endpointChange
+	seen := make(map[endpointKey]bool)
+	for _, ep := range inst.Endpoints {
+		k := endpointKey{service: inst.Name, endpoint: ep.Name}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		before := r.endpoints[k]
+		r.endpoints[k] = before + delta
+		if r.endpoints[k] <= 0 {
+			delete(r.endpoints, k)
+		}
+		if (before == 0) != (r.endpoints[k] == 0) {
+			changes = append(changes, &endpointChange{inst: inst, ep: ep})
+		}
+	}
+	return changes
 }
 
 // allInstances returns all registered instances within the region
 func (r *regionReg) allInstances() Instances {
 	r.RLock()
 	defer r.RUnlock()
-	ret := make(Instances, len(r.instances))
+	instances := r.view()
+	ret := make(Instances, len(instances))
 	i := 0
-	for _, inst := range r.instances {
+	for _, inst := range instances {
 		ret[i] = inst
 		i++
 	}
 	return ret
 }
 
+// snapshot returns all registered instances within the region, along with the revision they are from
+func (r *regionReg) snapshot() (Instances, uint64) {
+	r.RLock()
+	defer r.RUnlock()
+	instances := r.view()
+	ret := make(Instances, 0, len(instances))
+	for _, inst := range instances {
+		ret = append(ret, inst)
+	}
+	return ret, r.revision
+}
+
+// view returns the instances we serve: those restored from a snapshot until we've synced, and ours
+// thereafter; the caller must hold the lock
+func (r *regionReg) view() map[string]*Instance {
+	if !r.synced && r.loaded != nil {
+		return r.loaded
+	}
+	return r.instances
+}
+
+// stale returns whether we are serving instances restored from a snapshot, and when it was taken
+func (r *regionReg) stale() (bool, time.Time) {
+	r.RLock()
+	defer r.RUnlock()
+	return !r.synced && r.loaded != nil, r.loadedAt
+}
+
+// isSynced returns whether we have synced with ZK
+func (r *regionReg) isSynced() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.synced
+}
+
+// pathOf returns where an instance is stored, or an empty string if we don't know about it
+func (r *regionReg) pathOf(instId string) string {
+	r.RLock()
+	defer r.RUnlock()
+	return r.paths[instId]
+}
+
+// mzxidOf returns the ZK transaction that last modified our copy of an instance
+func (r *regionReg) mzxidOf(instId string) int64 {
+	r.RLock()
+	defer r.RUnlock()
+	return r.mzxids[instId]
+}
+
 // singleInstance returns one instance, by ID, or nil
 func (r *regionReg) singleInstance(instId string) *Instance {
 	r.RLock()
@@ -0,0 +1,352 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	servicedown "github.com/HailoOSS/discovery-service/proto/servicedown"
+	serviceup "github.com/HailoOSS/discovery-service/proto/serviceup"
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	"github.com/HailoOSS/protobuf/proto"
+)
+
+// fakeZk is a set of instance nodes beneath rootNode, either directly or beneath service nodes, satisfying zkConn
+type fakeZk struct {
+	sync.Mutex
+	docs     map[string][]byte
+	services map[string]bool
+	mzxid    int64
+	watches  map[string]chan gozk.Event
+}
+
+func newFakeZk() *fakeZk {
+	return &fakeZk{
+		docs:     make(map[string][]byte),
+		services: make(map[string]bool),
+		watches:  make(map[string]chan gozk.Event),
+	}
+}
+
+// putService stores an instance beneath its service node, in the hierarchical layout
+func (z *fakeZk) putService(t *testing.T, i *Instance) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		t.Fatalf("Failed to marshal instance: %v", err)
+	}
+	z.Lock()
+	defer z.Unlock()
+	z.mzxid++
+	z.docs[zkPathForServiceInstance(i.Name, i.Id)] = b
+	z.services[zkPathForService(i.Name)] = true
+}
+
+func (z *fakeZk) put(t *testing.T, i *Instance) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		t.Fatalf("Failed to marshal instance: %v", err)
+	}
+	z.Lock()
+	defer z.Unlock()
+	z.mzxid++
+	z.docs[zkPathForInstance(i.Id)] = b
+}
+
+func (z *fakeZk) remove(id string) {
+	z.Lock()
+	defer z.Unlock()
+	path := zkPathForInstance(id)
+	delete(z.docs, path)
+	if w, ok := z.watches[path]; ok {
+		w <- gozk.Event{Type: gozk.EventNodeDeleted, Path: path}
+		delete(z.watches, path)
+	}
+}
+
+func (z *fakeZk) children() []string {
+	return z.childrenOf(rootNode)
+}
+
+func (z *fakeZk) childrenOf(parent string) []string {
+	z.Lock()
+	defer z.Unlock()
+	ret := make([]string, 0, len(z.docs))
+	for path := range z.docs {
+		if parentOf(path) == parent {
+			ret = append(ret, nameOf(path))
+		}
+	}
+	for path := range z.services {
+		if parentOf(path) == parent {
+			ret = append(ret, nameOf(path))
+		}
+	}
+	return ret
+}
+
+func (z *fakeZk) Children(path string) ([]string, *gozk.Stat, error) {
+	return z.childrenOf(path), &gozk.Stat{}, nil
+}
+
+func (z *fakeZk) ChildrenW(path string) ([]string, *gozk.Stat, <-chan gozk.Event, error) {
+	return z.childrenOf(path), &gozk.Stat{}, make(chan gozk.Event, 1), nil
+}
+
+func (z *fakeZk) Get(path string) ([]byte, *gozk.Stat, error) {
+	z.Lock()
+	defer z.Unlock()
+	if z.services[path] {
+		return nil, &gozk.Stat{Mzxid: z.mzxid}, nil
+	}
+	b, ok := z.docs[path]
+	if !ok {
+		return nil, nil, gozk.ErrNoNode
+	}
+	return b, &gozk.Stat{EphemeralOwner: 1, DataLength: int32(len(b)), Mzxid: z.mzxid}, nil
+}
+
+func (z *fakeZk) GetW(path string) ([]byte, *gozk.Stat, <-chan gozk.Event, error) {
+	b, stat, err := z.Get(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	w := make(chan gozk.Event, 1)
+	z.Lock()
+	z.watches[path] = w
+	z.Unlock()
+	return b, stat, w, nil
+}
+
+// memPublishedStore holds the published log in memory
+type memPublishedStore struct {
+	sync.Mutex
+	b    []byte
+	puts int
+}
+
+func (s *memPublishedStore) get() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.b == nil {
+		return nil, gozk.ErrNoNode
+	}
+	return s.b, nil
+}
+
+func (s *memPublishedStore) put(b []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	s.b = b
+	s.puts++
+	return nil
+}
+
+// orderPublisher records the order of everything published, across all topics
+type orderPublisher struct {
+	sync.Mutex
+	topics []string
+}
+
+func (p *orderPublisher) publish(topic string, payload proto.Message) error {
+	p.Lock()
+	defer p.Unlock()
+	switch req := payload.(type) {
+	case *serviceup.Request:
+		topic += ":" + req.GetInstanceId()
+	case *servicedown.Request:
+		topic += ":" + req.GetInstanceId() + ":" + req.GetReason().String()
+	}
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func (p *orderPublisher) published() []string {
+	p.Lock()
+	defer p.Unlock()
+	return append([]string(nil), p.topics...)
+}
+
+// leadingRegion sets us up as leader of an empty region, with a fresh journal and published log, returning
+// a function to restore everything afterwards
+func leadingRegion(t *testing.T) (*regionReg, *orderPublisher, *memPublishedStore, func()) {
+	dir, err := ioutil.TempDir("", "discovery-journal")
+	if err != nil {
+		t.Fatalf("Failed to create journal dir: %v", err)
+	}
+
+	j, l, o, h, p, tf := journal, leader, publications, hooks, published, tombstoneOf
+	restore := func() {
+		journal, leader, publications, hooks, published, tombstoneOf = j, l, o, h, p, tf
+		os.RemoveAll(dir)
+	}
+
+	if journal, err = newEventJournal(dir); err != nil {
+		restore()
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	pub := &orderPublisher{}
+	store := &memPublishedStore{}
+	leader = &election{leading: true}
+	publications = newOutbox(pub, 1, time.Millisecond, time.Millisecond)
+	hooks = &webhookReg{hooks: make(map[string]*registeredHook)}
+	published = newPublishedLog(store)
+	tombstoneOf = func(id string) *tombstone {
+		return &tombstone{Reason: ReasonUnregister}
+	}
+
+	r := emptyRegionReg("test")
+	r.publish = true
+	return r, pub, store, restore
+}
+
+func TestAnnounceOrderOnReregistration(t *testing.T) {
+	r, pub, _, restore := leadingRegion(t)
+	defer restore()
+
+	z := newFakeZk()
+	sync := func() {
+		if _, err := r.sync(z, rootNode, z.children()); err != nil {
+			t.Fatalf("Failed to sync: %v", err)
+		}
+	}
+	sync()
+
+	i := &Instance{
+		Id:        "instance-1",
+		Name:      "com.HailoOSS.service.foo",
+		Endpoints: []*Endpoint{{Name: "bar"}},
+	}
+	z.put(t, i)
+	sync()
+	z.remove(i.Id)
+	sync()
+	z.put(t, i)
+	sync()
+
+	events, _, err := journal.replay(0, time.Time{}, MaxReplay)
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	var journalled []EventType
+	for _, e := range events {
+		journalled = append(journalled, e.Type)
+	}
+	expectOrder(t, "journalled", []EventType{EventUp, EventDown, EventUp}, journalled)
+
+	// everything is enqueued whilst syncing, but sent asynchronously
+	var sent []string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if sent = pub.published(); len(sent) >= 6 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var instanceEvents, endpointEvents []string
+	for _, p := range sent {
+		switch p {
+		case endpointUpTopic, endpointDownTopic:
+			endpointEvents = append(endpointEvents, p)
+		default:
+			instanceEvents = append(instanceEvents, p)
+		}
+	}
+	expectOrder(t, "instance events", []string{
+		serviceUpTopic + ":instance-1",
+		serviceDownTopic + ":instance-1:UNREGISTER",
+		serviceUpTopic + ":instance-1",
+	}, instanceEvents)
+	expectOrder(t, "endpoint events", []string{endpointUpTopic, endpointDownTopic, endpointUpTopic}, endpointEvents)
+}
+
+func expectOrder(t *testing.T, desc string, expected, got interface{}) {
+	eb, _ := json.Marshal(expected)
+	gb, _ := json.Marshal(got)
+	if string(eb) != string(gb) {
+		t.Errorf("Expected %v %s, got %s", desc, eb, gb)
+	}
+}
+
+func TestConfirmRechecksOutdated(t *testing.T) {
+	z := newFakeZk()
+	i := &Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"}
+	z.put(t, i)
+	z.put(t, i)
+	path := zkPathForInstance(i.Id)
+
+	r := emptyRegionReg("test")
+	found := []*discrepancy{{kind: driftOutdated, path: path}}
+	stored := map[string]*storedInstance{path: {inst: i, stat: &gozk.Stat{Mzxid: 1}}}
+
+	// our watch has since caught up
+	r.mzxids[i.Id] = 2
+	if confirmed := r.confirm(z, found, stored); len(confirmed) != 0 {
+		t.Errorf("Expected caught up instance not to be confirmed, got %v", confirmed)
+	}
+
+	// it hasn't, so what we reread is what gets applied
+	r.mzxids[i.Id] = 0
+	if confirmed := r.confirm(z, found, stored); len(confirmed) != 1 {
+		t.Errorf("Expected outdated instance to be confirmed, got %v", confirmed)
+	}
+	if mzxid := stored[path].stat.Mzxid; mzxid != 2 {
+		t.Errorf("Expected reread instance to be stored, got mzxid %v", mzxid)
+	}
+}
+
+func TestAnnounceMassDepartureOutsideLock(t *testing.T) {
+	r, _, store, restore := leadingRegion(t)
+	defer restore()
+
+	z := newFakeZk()
+	if _, err := r.sync(z, rootNode, z.children()); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	for n := 0; n < 50; n++ {
+		z.put(t, &Instance{Id: fmt.Sprintf("instance-%v", n), Name: "com.HailoOSS.service.foo"})
+	}
+	if _, err := r.sync(z, rootNode, z.children()); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	// reading tombstones goes to ZK, so mustn't happen whilst holding the region lock
+	locked := 0
+	tombstoneOf = func(id string) *tombstone {
+		read := make(chan struct{})
+		go func() {
+			r.RLock()
+			r.RUnlock()
+			close(read)
+		}()
+		select {
+		case <-read:
+		case <-time.After(time.Second):
+			locked++
+		}
+		return &tombstone{Reason: ReasonUnregister}
+	}
+
+	store.Lock()
+	puts := store.puts
+	store.Unlock()
+	for n := 0; n < 50; n++ {
+		z.remove(fmt.Sprintf("instance-%v", n))
+	}
+	if _, err := r.sync(z, rootNode, z.children()); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	if locked > 0 {
+		t.Errorf("Expected tombstones to be read without the region lock, but %v weren't", locked)
+	}
+	store.Lock()
+	defer store.Unlock()
+	if n := store.puts - puts; n != 1 {
+		t.Errorf("Expected a mass departure to be recorded as published in one write, got %v", n)
+	}
+}
@@ -1,31 +1,140 @@
 package registry
 
+import (
+	"time"
+
+	"github.com/HailoOSS/service/healthcheck"
+)
+
 const (
 	rootNode     = "/discovery-service"
 	instanceNode = "/discovery-service/%v"
+
+	// ReadyHealthCheckId identifies the healthcheck reporting whether we are ready to serve our region
+	ReadyHealthCheckId = "com.HailoOSS.kernel.discovery.ready"
 )
 
 var (
-	local  *localReg
-	region *regionReg
+	local   *localReg
+	region  *regionReg
+	leader  *election
+	peers   *federation
+	router  *routingReg
+	journal *eventJournal
+	hooks   *webhookReg
+
+	summaries    = &summaryCache{}
+	publications = newOutbox(platformPublisher{}, outboxAttempts, outboxBackoff, outboxMaxBackoff)
 )
 
 func Init() {
+	cfg := loadFederationConfig()
+
+	acls = loadACLs()
+	hierarchical = loadLayout()
+	instanceFormat = loadInstanceFormat()
+	journal = loadJournal()
+	snapshots = loadSnapshots()
 	local = newLocalReg()
-	region = newRegionReg()
+	leader = newElection(&Node{Id: local.id, Hostname: local.hostname})
+	region = newRegionReg(cfg.Region)
+	peers = newFederation(cfg)
+	router = newRoutingReg()
+	hooks = newWebhookReg()
+
+	// region-wide chores, run by whichever discovery node is leader
+	leader.addTask("reap", reapInterval, reapStaleInstances)
+	leader.addTask("tombstones", reapInterval, reapTombstones)
+	leader.addTask("summary", summaryInterval, logRegionSummary)
+	leader.addTask("acl", aclMigrateInterval, acls.migrate)
+	leader.addTask("versions", reapInterval, newVersionRecorder().record)
+
+	leader.start()
 }
 
 // Register registers an instance with this discovery service
 // Upon successful registration, this instance of the discovery service will
 // send periodic heartbeats to check the service it alive, and is then responsible
-// for removing it if it dies
+// for removing it if it dies. Registering an instance ID again is fine, so long as
+// the registration is the same; otherwise ErrRegistrationConflict is returned
 func Register(instance *Instance) error {
-	return local.add(instance)
+	return local.add(instance, false)
+}
+
+// UpdateRegistration registers an instance, replacing any existing registration with the same ID
+// (eg: to add endpoints); weight, labels and drain state are kept unless a weight or labels are supplied
+func UpdateRegistration(instance *Instance) error {
+	return local.add(instance, true)
 }
 
 // Unregister removes an instance, by ID, plus any endpoints running within this instance
 func Unregister(instanceId string) error {
-	return local.remove(instanceId)
+	return local.remove(instanceId, ReasonUnregister)
+}
+
+// Evict removes an instance, by ID, on behalf of an administrator rather than the instance itself
+func Evict(instanceId string) error {
+	return local.remove(instanceId, ReasonEviction)
+}
+
+// GetInstance returns a registered instance by ID, or ErrInstanceNotFound
+func GetInstance(instanceId string) (*Instance, error) {
+	return readInstance(instanceId)
+}
+
+// SetWeight adjusts the share of traffic an instance should receive, from 1 to MaxWeight
+func SetWeight(instanceId string, weight uint32) error {
+	if weight < 1 || weight > MaxWeight {
+		return ErrInvalidWeight
+	}
+	return updateInstance(instanceId, func(inst *Instance) error {
+		inst.Weight = weight
+		return nil
+	})
+}
+
+// SetLabels adds or changes labels of an instance, or removes those given an empty value
+func SetLabels(instanceId string, labels map[string]string) error {
+	for k, v := range labels {
+		if !validLabelKey.MatchString(k) || len(v) > MaxLabelValue {
+			return ErrInvalidLabels
+		}
+	}
+	return updateInstance(instanceId, func(inst *Instance) error {
+		changed := make(map[string]string, len(inst.Labels)+len(labels))
+		for k, v := range inst.Labels {
+			changed[k] = v
+		}
+		for k, v := range labels {
+			if v == "" {
+				delete(changed, k)
+			} else {
+				changed[k] = v
+			}
+		}
+		if sameLabels(changed, inst.Labels) {
+			return errUnchanged
+		}
+		if len(changed) > MaxLabels {
+			return ErrInvalidLabels
+		}
+		if len(changed) == 0 {
+			changed = nil
+		}
+		inst.Labels = changed
+		return nil
+	})
+}
+
+// SetDrained drains an instance of traffic, without unregistering it, or undrains it
+func SetDrained(instanceId string, drained bool) error {
+	return updateInstance(instanceId, func(inst *Instance) error {
+		if inst.Drained == drained {
+			return errUnchanged
+		}
+		inst.Drained = drained
+		return nil
+	})
 }
 
 func Hosts() ([]string, error) {
@@ -36,3 +145,105 @@ func Hosts() ([]string, error) {
 func AllInstances() Instances {
 	return region.allInstances()
 }
+
+// RegionInstances returns a snapshot of all instances within a named region, where an empty
+// name means our own region and AllRegions means every region we are federated with
+func RegionInstances(name string) (Instances, error) {
+	return peers.instances(name)
+}
+
+// RegionStale returns whether instances within a named region (as for RegionInstances) may be out of
+// date, since we are serving our region from a snapshot until we have synced with ZK
+func RegionStale(name string) bool {
+	if name != "" && name != AllRegions && name != region.name {
+		return false
+	}
+	stale, _ := region.stale()
+	return stale
+}
+
+// ReadyHealthCheck reports whether we are ready to serve our region, according to the configured
+// readiness policy
+func ReadyHealthCheck() healthcheck.Checker {
+	return snapshots.ready(region)
+}
+
+// Regions returns the names of all regions we are federated with, our own first
+func Regions() []string {
+	return peers.regions()
+}
+
+// RegionSummary returns a census of instances within the region, plus how many discovery nodes are running
+func RegionSummary() *Summary {
+	s := *summaries.get()
+	s.Nodes, s.NodesCounted = leader.nodeCount()
+	s.Stale, _ = region.stale()
+	return &s
+}
+
+// Leader returns the discovery node currently responsible for region-wide chores
+func Leader() (*Node, error) {
+	return leader.leader()
+}
+
+// Nodes returns all discovery nodes in the region, leader first
+func Nodes() ([]*Node, error) {
+	return leader.nodes()
+}
+
+// IsLeader returns whether this discovery node is currently leader
+func IsLeader() bool {
+	return leader.isLeader()
+}
+
+// Rules returns all routing rules, optionally just for one service
+func Rules(service string) []*Rule {
+	return router.rules(service)
+}
+
+// CreateRule adds a new routing rule, returning it with its newly assigned ID
+func CreateRule(rule *Rule) (*Rule, error) {
+	return createRule(rule)
+}
+
+// UpdateRule replaces an existing routing rule, matched on service and ID
+func UpdateRule(rule *Rule) error {
+	return updateRule(rule)
+}
+
+// DeleteRule removes a routing rule
+func DeleteRule(service, id string) error {
+	return deleteRule(service, id)
+}
+
+// Resolve returns the version(s) of a service a caller should use, plus the rule that pinned
+// it to a version, if any; callerId should be stable for a caller so it gets consistent decisions
+func Resolve(service, caller, callerId string) ([]uint64, *Rule) {
+	return router.resolve(service, caller, callerId)
+}
+
+// Replay returns up to limit journalled events after sequence number sinceSeq, or at/after since,
+// plus the last sequence number journalled; sequence numbers are only meaningful for this node
+func Replay(sinceSeq uint64, since time.Time, limit int) ([]*Event, uint64, error) {
+	return journal.replay(sinceSeq, since, limit)
+}
+
+// Webhooks returns all registered webhooks
+func Webhooks() []*Webhook {
+	return hooks.list()
+}
+
+// CreateWebhook registers a new webhook, returning it with its newly assigned ID
+func CreateWebhook(hook *Webhook) (*Webhook, error) {
+	return createWebhook(hook)
+}
+
+// DeleteWebhook removes a webhook
+func DeleteWebhook(id string) error {
+	return deleteWebhook(id)
+}
+
+// NodeId returns the ID of this discovery node
+func NodeId() string {
+	return local.id
+}
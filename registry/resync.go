@@ -0,0 +1,208 @@
+package registry
+
+import (
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	inst "github.com/HailoOSS/service/instrumentation"
+)
+
+// Kinds of drift between our copy of a region and ZK. Watches should keep the two in line, but can be
+// lost, eg: if a watcher fails to reregister in the window around a reconnection.
+const (
+	// driftMissing is an instance in ZK that we don't have
+	driftMissing = "missing"
+	// driftStale is an instance we have that is no longer in ZK
+	driftStale = "stale"
+	// driftOutdated is an instance whose document has changed since we read it
+	driftOutdated = "outdated"
+	// driftUnwatched is an instance or service node we have stopped watching
+	driftUnwatched = "unwatched"
+	// driftUndecodable is a document we can't read
+	driftUndecodable = "undecodable"
+)
+
+// discrepancy is a single difference between our copy of a region and ZK
+type discrepancy struct {
+	kind string
+	path string
+}
+
+// storedInstance is an instance document as read directly from ZK; inst is nil if it was undecodable
+type storedInstance struct {
+	inst *Instance
+	stat *gozk.Stat
+}
+
+// reconcile re-reads every instance in the region, checking our copy against it. Any drift is
+// reported, then repaired by updating outdated instances and resyncing the parent node of anything
+// else. Returns service nodes that need watching, as for sync.
+func (r *regionReg) reconcile(conn zkConn) ([]string, error) {
+	start := time.Now()
+
+	stored := make(map[string]*storedInstance)
+	var found []*discrepancy
+	read := func(path string) (*gozk.Stat, error) {
+		b, stat, err := conn.Get(path)
+		if err != nil {
+			return nil, err
+		}
+		if isServiceNode(stat) {
+			return stat, nil
+		}
+		i, err := decodeInstance(b)
+		if err != nil {
+			found = append(found, &discrepancy{kind: driftUndecodable, path: path})
+		}
+		stored[path] = &storedInstance{inst: i, stat: stat}
+		return stat, nil
+	}
+
+	children, _, err := conn.Children(rootNode)
+	if err != nil {
+		return nil, err
+	}
+	var services []string
+	for _, child := range children {
+		path := rootNode + "/" + child
+		stat, err := read(path)
+		if err == gozk.ErrNoNode {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !isServiceNode(stat) {
+			continue
+		}
+		services = append(services, child)
+		ids, _, err := conn.Children(path)
+		if err == gozk.ErrNoNode {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if _, err := read(path + "/" + id); err != nil && err != gozk.ErrNoNode {
+				return nil, err
+			}
+		}
+	}
+
+	found = append(found, r.confirm(conn, r.drift(conn, services, stored), stored)...)
+	inst.Timing(1.0, "resync", time.Since(start))
+	if len(found) == 0 {
+		log.Debugf("[Discovery] Reconciled %v instances in region %v, no drift found", len(stored), r.name)
+		return nil, nil
+	}
+
+	log.Warnf("[Discovery] Reconciling region %v found %v discrepancies, repairing", r.name, len(found))
+	parents := make(map[string]bool)
+	for _, d := range found {
+		log.Warnf("[Discovery] Drift in region %v: %v %v", r.name, d.kind, d.path)
+		inst.Counter(1.0, "resync.drift."+d.kind, 1)
+		switch d.kind {
+		case driftOutdated:
+			s := stored[d.path]
+			r.update(s.inst, s.stat)
+		case driftUndecodable:
+			// nothing we can do, and resyncing would only fail on it
+		default:
+			parents[parentOf(d.path)] = true
+		}
+	}
+
+	var watch []string
+	for parent := range parents {
+		s, err := r.resync(conn, parent)
+		if err != nil {
+			return watch, err
+		}
+		watch = append(watch, s...)
+	}
+	return watch, nil
+}
+
+// drift compares our copy of the region with instances read directly from ZK, keyed by path
+func (r *regionReg) drift(conn zkConn, services []string, stored map[string]*storedInstance) []*discrepancy {
+	r.RLock()
+	defer r.RUnlock()
+
+	var found []*discrepancy
+	for _, service := range services {
+		if r.services[service] != conn {
+			found = append(found, &discrepancy{kind: driftUnwatched, path: zkPathForService(service)})
+		}
+	}
+	for path, s := range stored {
+		id := nameOf(path)
+		ours, known := r.paths[id]
+		switch {
+		case s.inst == nil:
+			// already reported
+		case !known:
+			found = append(found, &discrepancy{kind: driftMissing, path: path})
+		case ours != path:
+			// registered twice, which sync already warns about
+		case r.watching[id] != conn:
+			found = append(found, &discrepancy{kind: driftUnwatched, path: path})
+		case s.stat.Mzxid > r.mzxids[id]:
+			found = append(found, &discrepancy{kind: driftOutdated, path: path})
+		}
+	}
+	for _, path := range r.paths {
+		if _, ok := stored[path]; !ok {
+			found = append(found, &discrepancy{kind: driftStale, path: path})
+		}
+	}
+	return found
+}
+
+// confirm rechecks instances that appear to be missing, stale or outdated, since they may just have
+// come, gone or changed since we read them, and our watch not yet caught up. Outdated instances that
+// are confirmed have their entry in stored refreshed with what we reread.
+func (r *regionReg) confirm(conn zkConn, found []*discrepancy, stored map[string]*storedInstance) []*discrepancy {
+	confirmed := make([]*discrepancy, 0, len(found))
+	for _, d := range found {
+		switch d.kind {
+		case driftOutdated:
+			b, stat, err := conn.Get(d.path)
+			if err != nil || stat.Mzxid <= r.mzxidOf(nameOf(d.path)) {
+				continue
+			}
+			i, err := decodeInstance(b)
+			if err != nil {
+				continue
+			}
+			stored[d.path] = &storedInstance{inst: i, stat: stat}
+		case driftMissing:
+			if _, _, err := conn.Get(d.path); err == gozk.ErrNoNode || r.pathOf(nameOf(d.path)) != "" {
+				continue
+			}
+		case driftStale:
+			if _, _, err := conn.Get(d.path); err != gozk.ErrNoNode || r.pathOf(nameOf(d.path)) != d.path {
+				continue
+			}
+		}
+		confirmed = append(confirmed, d)
+	}
+	return confirmed
+}
+
+// resync re-reads the children of a parent node and syncs them; unlike sync, the children are read
+// whilst holding the lock, so they can't be superseded by a sync from a watch that fired earlier
+func (r *regionReg) resync(conn zkConn, parent string) ([]string, error) {
+	defer r.flush()
+	r.Lock()
+	defer r.Unlock()
+
+	children, _, err := conn.Children(parent)
+	if err == gozk.ErrNoNode {
+		children, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.apply(conn, parent, children)
+}
@@ -0,0 +1,372 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/nu7hatch/gouuid"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const (
+	routingNode = "/discovery-service-routing"
+	ruleSetNode = "/discovery-service-routing/%v"
+)
+
+var (
+	// ErrRuleNotFound is returned when updating or deleting a rule that doesn't exist
+	ErrRuleNotFound = fmt.Errorf("Rule not found")
+	// ErrRuleConflict is returned when a rule set is modified by someone else whilst we're writing
+	ErrRuleConflict = fmt.Errorf("Rules modified concurrently, try again")
+)
+
+// InvalidRuleError is returned when a rule, or the rule set it would be part of, doesn't make sense
+type InvalidRuleError string
+
+func (e InvalidRuleError) Error() string {
+	return string(e)
+}
+
+// Rule pins a percentage of callers of a service to a specific version
+type Rule struct {
+	Id      string
+	Service string
+	Version uint64
+	// Percentage of matching callers, from 0 to 100, that should use Version
+	Percentage uint32
+	// CallerPrefix restricts this rule to callers whose name starts with it; empty matches everyone
+	CallerPrefix string
+}
+
+// matches returns whether this rule applies to the named caller
+func (r *Rule) matches(caller string) bool {
+	return strings.HasPrefix(caller, r.CallerPrefix)
+}
+
+// validate checks a single rule makes sense in isolation
+func (r *Rule) validate() error {
+	switch {
+	case r.Service == "":
+		return InvalidRuleError("Rule service is required")
+	case !ValidNodeName(r.Service):
+		return InvalidRuleError("Rule service may only contain letters, digits, '.', '_' and '-', and not start with '.'")
+	case r.Version == 0:
+		return InvalidRuleError("Rule version is required")
+	case r.Percentage > 100:
+		return InvalidRuleError("Rule percentage must be between 0 and 100")
+	}
+	return nil
+}
+
+// RuleSet is every rule for a single service, stored as one ZK document so it can be updated atomically
+type RuleSet struct {
+	Service string
+	Rules   []*Rule
+}
+
+// validate checks that, for any caller, the rules matching it do not exceed 100% in total. Prefixes
+// overlap (a caller matching "com.HailoOSS.service." also matches ""), so each prefix is totalled along
+// with every shorter prefix of it.
+func (rs *RuleSet) validate() error {
+	totals := make(map[string]uint32)
+	for _, r := range rs.Rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		totals[r.CallerPrefix] += r.Percentage
+	}
+	for prefix := range totals {
+		total := uint32(0)
+		for other, t := range totals {
+			if strings.HasPrefix(prefix, other) {
+				total += t
+			}
+		}
+		if total > 100 {
+			return InvalidRuleError(fmt.Sprintf("Rules for callers matching %q total more than 100%%", prefix))
+		}
+	}
+	return nil
+}
+
+// ---
+
+type routingReg struct {
+	sync.RWMutex
+	ruleSets map[string]*RuleSet
+	watching map[string]bool
+}
+
+func newRoutingReg() *routingReg {
+	r := &routingReg{
+		ruleSets: make(map[string]*RuleSet),
+		watching: make(map[string]bool),
+	}
+
+	ensureNode(routingNode)
+
+	go r.syncer()
+
+	return r
+}
+
+// syncer watches for new services with rules, launching a watcher for each
+func (r *routingReg) syncer() {
+	log.Debug("[Discovery] Launching routing syncer...")
+	for {
+		services, _, watch, err := zk.ChildrenW(routingNode)
+		if err != nil {
+			log.Warnf("[Discovery] Failed to read routing rules: %v -- delaying for %v", err, initDelay)
+			time.Sleep(initDelay)
+			continue
+		}
+
+		r.Lock()
+		for _, service := range services {
+			if !r.watching[service] {
+				r.watching[service] = true
+				go r.watchService(service)
+			}
+		}
+		r.Unlock()
+
+		e := <-watch
+		log.Debugf("[Discovery] Routing watch triggered for event %v", e)
+	}
+}
+
+// watchService keeps our copy of a service's rules up to date until the rule set is deleted
+func (r *routingReg) watchService(service string) {
+	defer func() {
+		r.Lock()
+		delete(r.ruleSets, service)
+		delete(r.watching, service)
+		r.Unlock()
+	}()
+
+	for {
+		b, _, watch, err := zk.GetW(zkPathForRuleSet(service))
+		if err == gozk.ErrNoNode {
+			return
+		} else if err != nil {
+			log.Warnf("[Discovery] Failed to read routing rules for %v: %v -- delaying for %v", service, err, initDelay)
+			time.Sleep(initDelay)
+			continue
+		}
+
+		rs := &RuleSet{}
+		if err := json.Unmarshal(b, rs); err != nil {
+			log.Warnf("[Discovery] Failed to unmarshal routing rules for %v: %v", service, err)
+		} else {
+			r.Lock()
+			r.ruleSets[service] = rs
+			r.Unlock()
+		}
+
+		e := <-watch
+		log.Debugf("[Discovery] Routing watch for %v triggered for event %v", service, e)
+	}
+}
+
+// rules returns all rules, optionally just for one service
+func (r *routingReg) rules(service string) []*Rule {
+	r.RLock()
+	defer r.RUnlock()
+
+	ret := make([]*Rule, 0)
+	for name, rs := range r.ruleSets {
+		if service == "" || name == service {
+			ret = append(ret, rs.Rules...)
+		}
+	}
+	return ret
+}
+
+// resolve decides which version(s) a caller should use; callerId keeps a caller sticky to the
+// same decision (without one, each call is decided afresh), and callers not pinned by any rule may
+// use any running version that isn't pinned
+func (r *routingReg) resolve(service, caller, callerId string) ([]uint64, *Rule) {
+	r.RLock()
+	rs := r.ruleSets[service]
+	r.RUnlock()
+
+	return resolveVersions(rs, AllInstances().Filter(MatchingService(service)), service, caller, callerId)
+}
+
+// resolveVersions does the work of resolve, given a service's rules and its running instances. A rule
+// pinning a version with no running instances is ignored, so its callers get the default instead of
+// being sent nowhere.
+func resolveVersions(rs *RuleSet, running Instances, service, caller, callerId string) ([]uint64, *Rule) {
+	var all []uint64
+	seen := make(map[uint64]bool)
+	for _, inst := range running {
+		if !seen[inst.Version] {
+			seen[inst.Version] = true
+			all = append(all, inst.Version)
+		}
+	}
+
+	pinned := make(map[uint64]bool)
+	if rs != nil {
+		bucket := routingBucket(service, callerId)
+		cumulative := uint32(0)
+		skipped := false
+		for _, rule := range rs.Rules {
+			if !rule.matches(caller) {
+				continue
+			}
+			cumulative += rule.Percentage
+			if bucket < cumulative && !skipped {
+				if seen[rule.Version] {
+					return []uint64{rule.Version}, rule
+				}
+				// rather than falling through to the next rule, which would then get more than its share
+				skipped = true
+			}
+			pinned[rule.Version] = true
+		}
+	}
+
+	var unpinned []uint64
+	for _, v := range all {
+		if !pinned[v] {
+			unpinned = append(unpinned, v)
+		}
+	}
+
+	// if every running version is pinned, better to send callers somewhere than nowhere
+	if len(unpinned) == 0 {
+		unpinned = all
+	}
+	sort.Sort(sort.Reverse(versions(unpinned)))
+	return unpinned, nil
+}
+
+// routingBucket deterministically assigns a caller to one of 100 buckets for a service, or picks one at
+// random for a caller we can't identify
+func routingBucket(service, callerId string) uint32 {
+	if callerId == "" {
+		return uint32(rand.Intn(100))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(service + "|" + callerId))
+	return h.Sum32() % 100
+}
+
+type versions []uint64
+
+func (v versions) Len() int           { return len(v) }
+func (v versions) Less(i, j int) bool { return v[i] < v[j] }
+func (v versions) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// ---
+
+// modifyRuleSet applies fn to the stored rule set for a service, creating it if need be and
+// deleting it once empty; fn's changes are only written if the resulting rule set is valid
+func modifyRuleSet(service string, fn func(rs *RuleSet) error) error {
+	path := zkPathForRuleSet(service)
+
+	rs := &RuleSet{Service: service}
+	b, stat, err := zk.Get(path)
+	exists := err == nil
+	if err != nil && err != gozk.ErrNoNode {
+		return err
+	}
+	if exists {
+		if err := json.Unmarshal(b, rs); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(rs); err != nil {
+		return err
+	}
+	if err := rs.validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case !exists && len(rs.Rules) == 0:
+		return nil
+	case exists && len(rs.Rules) == 0:
+		err = zk.Delete(path, stat.Version)
+	default:
+		if b, err = json.Marshal(rs); err != nil {
+			return fmt.Errorf("Failed to marshal rules JSON: %v", err)
+		}
+		if exists {
+			_, err = zk.Set(path, b, stat.Version)
+		} else {
+			_, err = zk.Create(path, b, 0, acls.forPath(path))
+		}
+	}
+
+	if err == gozk.ErrBadVersion || err == gozk.ErrNodeExists || err == gozk.ErrNoNode {
+		return ErrRuleConflict
+	}
+	return err
+}
+
+// createRule adds a new rule, assigning it an ID
+func createRule(rule *Rule) (*Rule, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	rule.Id = id.String()
+
+	err = modifyRuleSet(rule.Service, func(rs *RuleSet) error {
+		rs.Rules = append(rs.Rules, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// updateRule replaces an existing rule, matched on service and ID
+func updateRule(rule *Rule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	return modifyRuleSet(rule.Service, func(rs *RuleSet) error {
+		for i, r := range rs.Rules {
+			if r.Id == rule.Id {
+				rs.Rules[i] = rule
+				return nil
+			}
+		}
+		return ErrRuleNotFound
+	})
+}
+
+// deleteRule removes a rule by service and ID
+func deleteRule(service, id string) error {
+	return modifyRuleSet(service, func(rs *RuleSet) error {
+		for i, r := range rs.Rules {
+			if r.Id == id {
+				rs.Rules = append(rs.Rules[:i], rs.Rules[i+1:]...)
+				return nil
+			}
+		}
+		return ErrRuleNotFound
+	})
+}
+
+// zkPathForRuleSet gets path for a service's rules
+func zkPathForRuleSet(service string) string {
+	return fmt.Sprintf(ruleSetNode, service)
+}
@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRuleSetValidate(t *testing.T) {
+	rule := func(prefix string, percentage uint32) *Rule {
+		return &Rule{Service: "com.HailoOSS.service.foo", Version: 1, Percentage: percentage, CallerPrefix: prefix}
+	}
+
+	testCases := []struct {
+		desc  string
+		rules []*Rule
+		valid bool
+	}{
+		{"no rules", nil, true},
+		{"one rule", []*Rule{rule("", 50)}, true},
+		{"missing service", []*Rule{{Version: 1, Percentage: 10}}, false},
+		{"service with a slash", []*Rule{{Service: "com.HailoOSS/foo", Version: 1, Percentage: 10}}, false},
+		{"missing version", []*Rule{{Service: "com.HailoOSS.service.foo", Percentage: 10}}, false},
+		{"percentage over 100", []*Rule{rule("", 101)}, false},
+		{"same prefix up to 100", []*Rule{rule("", 60), rule("", 40)}, true},
+		{"same prefix over 100", []*Rule{rule("", 60), rule("", 41)}, false},
+		{"disjoint prefixes", []*Rule{rule("com.HailoOSS.service.", 60), rule("com.HailoOSS.kernel.", 60)}, true},
+		{"overlapping prefixes up to 100", []*Rule{rule("", 60), rule("com.HailoOSS.", 40)}, true},
+		{"overlapping prefixes over 100", []*Rule{rule("", 60), rule("com.HailoOSS.", 50)}, false},
+		{"nested prefixes over 100", []*Rule{
+			rule("com.", 30),
+			rule("com.HailoOSS.", 30),
+			rule("com.HailoOSS.service.", 50),
+		}, false},
+		{"nested prefixes each under 100 with a sibling", []*Rule{
+			rule("com.", 30),
+			rule("com.HailoOSS.service.", 70),
+			rule("com.HailoOSS.kernel.", 70),
+		}, true},
+	}
+
+	for _, tc := range testCases {
+		rs := &RuleSet{Service: "com.HailoOSS.service.foo", Rules: tc.rules}
+		err := rs.validate()
+		if tc.valid && err != nil {
+			t.Errorf("%v: expected to be valid, got %v", tc.desc, err)
+		}
+		if _, ok := err.(InvalidRuleError); !tc.valid && !ok {
+			t.Errorf("%v: expected InvalidRuleError, got %v", tc.desc, err)
+		}
+	}
+}
+
+func TestResolveVersions(t *testing.T) {
+	const service = "com.HailoOSS.service.foo"
+	running := func(versions ...uint64) Instances {
+		var ret Instances
+		for i, v := range versions {
+			ret = append(ret, &Instance{Id: fmt.Sprintf("instance-%v", i), Name: service, Version: v})
+		}
+		return ret
+	}
+	rules := func(rules ...*Rule) *RuleSet {
+		return &RuleSet{Service: service, Rules: rules}
+	}
+
+	testCases := []struct {
+		desc     string
+		rs       *RuleSet
+		running  Instances
+		caller   string
+		expected []uint64
+		ruleId   string
+	}{
+		{"no rules", nil, running(1, 2, 2, 3), "com.HailoOSS.service.bar", []uint64{3, 2, 1}, ""},
+		{"pinned", rules(&Rule{Id: "r1", Version: 2, Percentage: 100}), running(1, 2, 3), "com.HailoOSS.service.bar", []uint64{2}, "r1"},
+		{"not matching caller", rules(&Rule{Id: "r1", Version: 2, Percentage: 100, CallerPrefix: "com.HailoOSS.kernel."}),
+			running(1, 2, 3), "com.HailoOSS.service.bar", []uint64{3, 2, 1}, ""},
+		{"unpinned get unpinned versions", rules(&Rule{Id: "r1", Version: 2, Percentage: 0}),
+			running(1, 2, 3), "com.HailoOSS.service.bar", []uint64{3, 1}, ""},
+		{"every version pinned", rules(&Rule{Id: "r1", Version: 2, Percentage: 0}),
+			running(2), "com.HailoOSS.service.bar", []uint64{2}, ""},
+		{"pinned version not running", rules(&Rule{Id: "r1", Version: 4, Percentage: 100}),
+			running(1, 2), "com.HailoOSS.service.bar", []uint64{2, 1}, ""},
+		{"pinned version not running, with a rule after it", rules(
+			&Rule{Id: "r1", Version: 4, Percentage: 100},
+			&Rule{Id: "r2", Version: 2, Percentage: 0, CallerPrefix: "com."},
+		), running(1, 2), "com.HailoOSS.service.bar", []uint64{1}, ""},
+		{"nothing running", rules(&Rule{Id: "r1", Version: 4, Percentage: 100}), nil, "com.HailoOSS.service.bar", nil, ""},
+	}
+
+	for _, tc := range testCases {
+		versions, rule := resolveVersions(tc.rs, tc.running, service, tc.caller, "instance-1")
+		expectOrder(t, tc.desc, tc.expected, versions)
+		ruleId := ""
+		if rule != nil {
+			ruleId = rule.Id
+		}
+		if ruleId != tc.ruleId {
+			t.Errorf("%v: expected rule %q, got %q", tc.desc, tc.ruleId, ruleId)
+		}
+	}
+}
+
+func TestResolveSplitsCallers(t *testing.T) {
+	const service = "com.HailoOSS.service.foo"
+	rs := &RuleSet{Service: service, Rules: []*Rule{{Id: "canary", Version: 2, Percentage: 30}}}
+	running := Instances{
+		&Instance{Id: "instance-1", Name: service, Version: 1},
+		&Instance{Id: "instance-2", Name: service, Version: 2},
+	}
+
+	// instances of the same calling service are split between versions, each sticking to its own
+	pinned := 0
+	for i := 0; i < 1000; i++ {
+		callerId := fmt.Sprintf("caller-instance-%v", i)
+		_, rule := resolveVersions(rs, running, service, "com.HailoOSS.service.bar", callerId)
+		if _, again := resolveVersions(rs, running, service, "com.HailoOSS.service.bar", callerId); (rule == nil) != (again == nil) {
+			t.Fatalf("Expected %v to get the same answer every time", callerId)
+		}
+		if rule != nil {
+			pinned++
+		}
+	}
+	if pinned < 200 || pinned > 400 {
+		t.Errorf("Expected about 30%% of callers to be pinned, got %v of 1000", pinned)
+	}
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// NotDrained filters out drained instances
+func NotDrained() Filter {
+	return func(inst *Instance) bool {
+		return inst.Drained
+	}
+}
+
+// Healthy filters out instances we know to be failing heartbeats; we only have an opinion
+// on those registered with us, since anything else is removed by its owner when it dies
+func Healthy() Filter {
+	return func(inst *Instance) bool {
+		healthy, known := local.healthy(inst.Id)
+		return known && !healthy
+	}
+}
+
+// Candidates returns healthy, undrained instances of a service ordered by preference for
+// a caller within az
+func Candidates(service, az string) Instances {
+	return AllInstances().
+		Filter(MatchingService(service)).
+		Filter(NotDrained()).
+		Filter(Healthy()).
+		Spread(az)
+}
+
+// Spread orders instances so that those within az come first, and within each AZ consecutive
+// instances are on different hosts wherever possible; order is otherwise random, biased by
+// weight, so that callers spread their load in proportion.
+//
+// AZ takes precedence over weight: weight only orders instances within the same AZ group, so
+// even the lightest instance in az comes before the heaviest elsewhere. Drain an instance, rather
+// than lowering its weight, to move traffic out of an AZ.
+func (list Instances) Spread(az string) Instances {
+	var same, other Instances
+	for _, inst := range list {
+		if az != "" && inst.AzName == az {
+			same = append(same, inst)
+		} else {
+			other = append(other, inst)
+		}
+	}
+
+	return append(same.spreadHosts(), other.spreadHosts()...)
+}
+
+// spreadHosts interleaves weighted-shuffled instances across hosts, taking one from each host in turn
+func (list Instances) spreadHosts() Instances {
+	byHost := make(map[string]Instances)
+	hosts := make([]string, 0)
+	for _, inst := range list.weightedShuffle() {
+		if _, ok := byHost[inst.Hostname]; !ok {
+			hosts = append(hosts, inst.Hostname)
+		}
+		byHost[inst.Hostname] = append(byHost[inst.Hostname], inst)
+	}
+
+	ret := make(Instances, 0, len(list))
+	for len(ret) < len(list) {
+		for _, h := range hosts {
+			if insts := byHost[h]; len(insts) > 0 {
+				ret = append(ret, insts[0])
+				byHost[h] = insts[1:]
+			}
+		}
+	}
+	return ret
+}
+
+// weightedShuffle returns a random ordering where the chance of an instance appearing ahead of
+// another is proportional to its weight (weighted sampling without replacement, keyed on u^(1/w))
+func (list Instances) weightedShuffle() Instances {
+	w := &weighted{
+		insts: make(Instances, len(list)),
+		keys:  make([]float64, len(list)),
+	}
+	for i, inst := range list {
+		w.insts[i] = inst
+		w.keys[i] = math.Pow(rand.Float64(), 1/float64(inst.GetWeight()))
+	}
+	sort.Sort(w)
+	return w.insts
+}
+
+// weighted sorts instances by descending key
+type weighted struct {
+	insts Instances
+	keys  []float64
+}
+
+func (w *weighted) Len() int           { return len(w.insts) }
+func (w *weighted) Less(i, j int) bool { return w.keys[i] > w.keys[j] }
+func (w *weighted) Swap(i, j int) {
+	w.insts[i], w.insts[j] = w.insts[j], w.insts[i]
+	w.keys[i], w.keys[j] = w.keys[j], w.keys[i]
+}
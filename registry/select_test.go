@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSpreadPrefersAzOverWeight(t *testing.T) {
+	list := Instances{
+		&Instance{Id: "far-1", AzName: "eu-west-1b", Hostname: "host-1", Weight: MaxWeight},
+		&Instance{Id: "near-1", AzName: "eu-west-1a", Hostname: "host-2", Weight: 1},
+		&Instance{Id: "far-2", AzName: "eu-west-1b", Hostname: "host-3", Weight: MaxWeight},
+		&Instance{Id: "near-2", AzName: "eu-west-1a", Hostname: "host-4", Weight: 1},
+	}
+
+	for n := 0; n < 100; n++ {
+		spread := list.Spread("eu-west-1a")
+		if len(spread) != len(list) {
+			t.Fatalf("Expected %v instances, got %v", len(list), len(spread))
+		}
+		for i, inst := range spread {
+			if near := i < 2; near != (inst.AzName == "eu-west-1a") {
+				t.Fatalf("Expected instances in our AZ first regardless of weight, got %v at %v", inst.Id, i)
+			}
+		}
+	}
+}
+
+func TestSpreadWeightsWithinAz(t *testing.T) {
+	list := Instances{
+		&Instance{Id: "heavy", AzName: "eu-west-1a", Hostname: "host-1", Weight: 90},
+		&Instance{Id: "light", AzName: "eu-west-1a", Hostname: "host-2", Weight: 10},
+	}
+
+	first := make(map[string]int)
+	for n := 0; n < 2000; n++ {
+		first[list.Spread("eu-west-1a")[0].Id]++
+	}
+
+	// Expect the heavy instance first ~90% of the time; allow plenty of slack
+	if first["heavy"] < 1600 || first["light"] < 100 {
+		t.Errorf("Expected weight to bias the order within an AZ, got %v", first)
+	}
+}
+
+func TestSpreadHosts(t *testing.T) {
+	var list Instances
+	for i := 0; i < 6; i++ {
+		list = append(list, &Instance{
+			Id:       fmt.Sprintf("instance-%v", i),
+			Hostname: fmt.Sprintf("host-%v", i%2),
+		})
+	}
+
+	spread := list.Spread("")
+	for i := 1; i < len(spread); i++ {
+		if spread[i].Hostname == spread[i-1].Hostname {
+			t.Fatalf("Expected consecutive instances on different hosts, got %v twice at %v", spread[i].Hostname, i)
+		}
+	}
+}
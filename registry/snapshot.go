@@ -0,0 +1,242 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/HailoOSS/service/config"
+	"github.com/HailoOSS/service/healthcheck"
+)
+
+const (
+	snapshotInterval = time.Minute
+
+	// ReadyOnSync reports us ready only once we have synced our region with ZK
+	ReadyOnSync = "sync"
+	// ReadyOnSnapshot also reports us ready whilst serving a snapshot that is no older than its maximum age
+	ReadyOnSnapshot = "snapshot"
+)
+
+// snapshotConfig configures snapshots of our region on local disk, at hailo.service.discovery.snapshot
+type snapshotConfig struct {
+	// File is where the snapshot is kept, in a directory only we can write to; empty (the default)
+	// disables snapshots
+	File string
+	// MaxAge is the oldest snapshot we'll serve, in seconds
+	MaxAge int
+	// Readiness is the policy deciding when we are ready: ReadyOnSync or ReadyOnSnapshot
+	Readiness string
+}
+
+// regionSnapshot is our region as written to disk
+type regionSnapshot struct {
+	Region    string
+	Taken     time.Time
+	Instances []*snapshotInstance
+}
+
+// snapshotInstance is an instance document, in the same schema as we store in ZK, plus what we
+// otherwise assign on sync
+type snapshotInstance struct {
+	Registered time.Time
+	Document   json.RawMessage
+}
+
+// snapshotStore periodically writes our region to local disk, so that after restarting we can serve it
+// straight away, marked stale, whilst syncing with ZK
+type snapshotStore struct {
+	file      string
+	maxAge    time.Duration
+	readiness string
+}
+
+// snapshots defaults to disabled until Init has loaded configuration
+var snapshots = &snapshotStore{readiness: ReadyOnSync}
+
+func loadSnapshots() *snapshotStore {
+	cfg := &snapshotConfig{
+		MaxAge:    3600,
+		Readiness: ReadyOnSync,
+	}
+	if err := config.AtPath("hailo", "service", "discovery", "snapshot").AsStruct(cfg); err != nil {
+		log.Warnf("[Discovery] Failed to load snapshot config, using defaults: %v", err)
+	}
+	if cfg.Readiness != ReadyOnSync && cfg.Readiness != ReadyOnSnapshot {
+		log.Warnf("[Discovery] Unknown readiness policy %v, using %v", cfg.Readiness, ReadyOnSync)
+		cfg.Readiness = ReadyOnSync
+	}
+	return &snapshotStore{
+		file:      cfg.File,
+		maxAge:    time.Duration(cfg.MaxAge) * time.Second,
+		readiness: cfg.Readiness,
+	}
+}
+
+// restore loads the snapshot of a region, if there is a recent enough one, to serve until it has synced
+func (s *snapshotStore) restore(r *regionReg) {
+	if s.file == "" {
+		return
+	}
+	b, err := s.read()
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Warnf("[Discovery] Failed to read snapshot %v: %v", s.file, err)
+		return
+	}
+	snap := &regionSnapshot{}
+	if err := json.Unmarshal(b, snap); err != nil {
+		log.Warnf("[Discovery] Failed to unmarshal snapshot %v: %v", s.file, err)
+		return
+	}
+	if snap.Region != r.name {
+		log.Warnf("[Discovery] Ignoring snapshot %v, since it is of region %v", s.file, snap.Region)
+		return
+	}
+	if age := time.Since(snap.Taken); age > s.maxAge {
+		log.Infof("[Discovery] Ignoring snapshot %v, since it is %v old", s.file, age)
+		return
+	}
+
+	loaded := make(map[string]*Instance, len(snap.Instances))
+	for _, si := range snap.Instances {
+		i, err := unmarshalInstance(si.Document)
+		if err != nil {
+			log.Warnf("[Discovery] Ignoring snapshot %v, since it has an invalid instance: %v", s.file, err)
+			return
+		}
+		i.Region = r.name
+		i.Registered = si.Registered
+		loaded[i.Id] = i
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.loaded = loaded
+	r.loadedAt = snap.Taken
+	r.revision++
+	log.Infof("[Discovery] Serving %v instances from snapshot taken at %v until synced", len(loaded), snap.Taken)
+}
+
+// read returns the contents of our snapshot, refusing to follow a symlink or to trust a file that anyone
+// but us could have written
+func (s *snapshotStore) read() ([]byte, error) {
+	f, err := os.OpenFile(s.file, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("Not a regular file")
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != os.Getuid() {
+		return nil, fmt.Errorf("Not owned by us")
+	}
+	if fi.Mode().Perm()&0022 != 0 {
+		return nil, fmt.Errorf("Writable by others (%v)", fi.Mode().Perm())
+	}
+	return ioutil.ReadAll(f)
+}
+
+// writer continually snapshots a region, once it has synced
+func (s *snapshotStore) writer(r *regionReg) {
+	if s.file == "" {
+		return
+	}
+	tick := time.NewTicker(snapshotInterval)
+	for {
+		<-tick.C
+		if !r.isSynced() {
+			continue
+		}
+		if err := s.write(r); err != nil {
+			log.Warnf("[Discovery] Failed to write snapshot %v: %v", s.file, err)
+		}
+	}
+}
+
+// write snapshots a region, replacing the previous snapshot atomically so we never load half of one
+func (s *snapshotStore) write(r *regionReg) error {
+	instances, _ := r.snapshot()
+	snap := &regionSnapshot{
+		Region:    r.name,
+		Taken:     time.Now(),
+		Instances: make([]*snapshotInstance, 0, len(instances)),
+	}
+	for _, i := range instances {
+		b, err := marshalInstance(i)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal instance %v: %v", i.Id, err)
+		}
+		snap.Instances = append(snap.Instances, &snapshotInstance{Registered: i.Registered, Document: b})
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.file)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// TempFile creates a new file, readable only by us, so we can't be tricked into writing elsewhere
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(s.file)+".")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.file); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// ready returns a healthcheck reporting whether a region is ready to be served, according to our
+// readiness policy
+func (s *snapshotStore) ready(r *regionReg) healthcheck.Checker {
+	return func() (map[string]string, error) {
+		synced := r.isSynced()
+		stale, taken := r.stale()
+		details := map[string]string{
+			"region":    r.name,
+			"synced":    strconv.FormatBool(synced),
+			"stale":     strconv.FormatBool(stale),
+			"readiness": s.readiness,
+		}
+		if synced {
+			return details, nil
+		}
+		if !stale {
+			return details, fmt.Errorf("Region %v not yet synced with ZK", r.name)
+		}
+
+		age := time.Since(taken)
+		details["snapshotAge"] = age.String()
+		if s.readiness == ReadyOnSnapshot && age <= s.maxAge {
+			return details, nil
+		}
+		return details, fmt.Errorf("Region %v not yet synced with ZK, serving a snapshot %v old", r.name, age)
+	}
+}
@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFileSafety(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery-snapshot")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &snapshotStore{file: filepath.Join(dir, "snapshot.json"), maxAge: time.Hour, readiness: ReadyOnSync}
+	if err := s.write(emptyRegionReg("test")); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	fi, err := os.Stat(s.file)
+	if err != nil {
+		t.Fatalf("Failed to stat snapshot: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected snapshot to be written 0600, got %v", perm)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected only the snapshot to be left behind, got %v files", len(files))
+	}
+
+	restored := func() bool {
+		r := emptyRegionReg("test")
+		s.restore(r)
+		return !r.loadedAt.IsZero()
+	}
+	if !restored() {
+		t.Fatalf("Expected our own snapshot to be restored")
+	}
+
+	if err := os.Chmod(s.file, 0666); err != nil {
+		t.Fatalf("Failed to chmod snapshot: %v", err)
+	}
+	if restored() {
+		t.Errorf("Expected a world-writable snapshot not to be restored")
+	}
+
+	target := filepath.Join(dir, "target.json")
+	if err := os.Chmod(s.file, 0600); err != nil {
+		t.Fatalf("Failed to chmod snapshot: %v", err)
+	}
+	if err := os.Rename(s.file, target); err != nil {
+		t.Fatalf("Failed to move snapshot: %v", err)
+	}
+	if err := os.Symlink(target, s.file); err != nil {
+		t.Fatalf("Failed to symlink snapshot: %v", err)
+	}
+	if restored() {
+		t.Errorf("Expected a symlinked snapshot not to be restored")
+	}
+}
+
+func TestSnapshotServedUntilEveryServiceSynced(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery-snapshot")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	foo := &Instance{Id: "instance-1", Name: "com.HailoOSS.service.foo"}
+	bar := &Instance{Id: "instance-2", Name: "com.HailoOSS.service.bar"}
+	z := newFakeZk()
+	z.putService(t, foo)
+	z.putService(t, bar)
+
+	// snapshot a region that has seen both services
+	sync := func(r *regionReg, parent string) []string {
+		services, err := r.sync(z, parent, z.childrenOf(parent))
+		if err != nil {
+			t.Fatalf("Failed to sync %v: %v", parent, err)
+		}
+		return services
+	}
+	before := emptyRegionReg("test")
+	for _, service := range sync(before, rootNode) {
+		sync(before, zkPathForService(service))
+	}
+	if len(before.allInstances()) != 2 {
+		t.Fatalf("Expected 2 instances to snapshot, got %v", len(before.allInstances()))
+	}
+	s := &snapshotStore{file: filepath.Join(dir, "snapshot.json"), maxAge: time.Hour, readiness: ReadyOnSync}
+	if err := s.write(before); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	// restore it, then sync the root and only one of the services
+	r := emptyRegionReg("test")
+	s.restore(r)
+	if stale, _ := r.stale(); !stale {
+		t.Fatalf("Expected to serve the restored snapshot")
+	}
+	services := sync(r, rootNode)
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 service nodes to watch, got %v", services)
+	}
+	sync(r, zkPathForService(services[0]))
+	if stale, _ := r.stale(); !stale || r.isSynced() {
+		t.Errorf("Expected to keep serving the snapshot until every service has synced")
+	}
+	if n := len(r.allInstances()); n != 2 {
+		t.Errorf("Expected both instances from the snapshot whilst partially synced, got %v", n)
+	}
+
+	sync(r, zkPathForService(services[1]))
+	if stale, _ := r.stale(); stale || !r.isSynced() {
+		t.Errorf("Expected to be synced, and to drop the snapshot, once every service has synced")
+	}
+	if n := len(r.allInstances()); n != 2 {
+		t.Errorf("Expected both instances once synced, got %v", n)
+	}
+}
@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// Subscription is an endpoint of a single version of a service that subscribes to a topic, along
+// with the instances running it
+type Subscription struct {
+	Topic          string
+	ServiceName    string
+	ServiceVersion uint64
+	EndpointName   string
+	InstanceIds    []string
+}
+
+// Subscribers returns who subscribes to a topic, or to any topic starting with it if prefix is set,
+// ordered by topic, service, version and endpoint
+func (is Instances) Subscribers(topic string, prefix bool) []*Subscription {
+	type key struct {
+		topic    string
+		service  string
+		version  uint64
+		endpoint string
+	}
+
+	byKey := make(map[key]*Subscription)
+	ret := make(subscriptions, 0)
+	for _, inst := range is {
+		for _, ep := range inst.Endpoints {
+			if ep.Subscribe == "" {
+				continue
+			}
+			if ep.Subscribe != topic && !(prefix && strings.HasPrefix(ep.Subscribe, topic)) {
+				continue
+			}
+
+			k := key{ep.Subscribe, inst.Name, inst.Version, ep.Name}
+			sub, ok := byKey[k]
+			if !ok {
+				sub = &Subscription{
+					Topic:          ep.Subscribe,
+					ServiceName:    inst.Name,
+					ServiceVersion: inst.Version,
+					EndpointName:   ep.Name,
+					InstanceIds:    make([]string, 0),
+				}
+				byKey[k] = sub
+				ret = append(ret, sub)
+			}
+			sub.InstanceIds = append(sub.InstanceIds, inst.Id)
+		}
+	}
+
+	sort.Sort(ret)
+	for _, sub := range ret {
+		sort.Strings(sub.InstanceIds)
+	}
+	return ret
+}
+
+type subscriptions []*Subscription
+
+func (s subscriptions) Len() int      { return len(s) }
+func (s subscriptions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s subscriptions) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	switch {
+	case a.Topic != b.Topic:
+		return a.Topic < b.Topic
+	case a.ServiceName != b.ServiceName:
+		return a.ServiceName < b.ServiceName
+	case a.ServiceVersion != b.ServiceVersion:
+		return a.ServiceVersion < b.ServiceVersion
+	}
+	return a.EndpointName < b.EndpointName
+}
@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"sync"
+)
+
+// Summary is a census of the region
+type Summary struct {
+	// Revision identifies the snapshot of the region this was computed from
+	Revision  uint64
+	Instances int
+	Hosts     int
+	// Nodes is how many discovery nodes are running in the region, if NodesCounted; they are counted
+	// from a watch, so aren't known for a moment after we start
+	Nodes          int
+	NodesCounted   bool
+	Services       map[string]int
+	Versions       map[string]map[uint64]int
+	AzNames        map[string]int
+	MachineClasses map[string]int
+	// Stale is set whilst we are serving the region from a snapshot, having not yet synced with ZK
+	Stale bool
+}
+
+// summaryCache holds the census for the latest revision of the region, since it only changes
+// when instances come and go
+type summaryCache struct {
+	sync.Mutex
+	summary *Summary
+}
+
+// get returns the census for the current revision of the region, computing it if need be. The
+// summary returned is shared, so mustn't be modified.
+func (c *summaryCache) get() *Summary {
+	instances, revision := region.snapshot()
+
+	c.Lock()
+	defer c.Unlock()
+	if c.summary == nil || c.summary.Revision != revision {
+		c.summary = summarise(instances, revision)
+	}
+	return c.summary
+}
+
+func summarise(instances Instances, revision uint64) *Summary {
+	s := &Summary{
+		Revision:       revision,
+		Instances:      len(instances),
+		Services:       make(map[string]int),
+		Versions:       make(map[string]map[uint64]int),
+		AzNames:        make(map[string]int),
+		MachineClasses: make(map[string]int),
+	}
+
+	hosts := make(map[string]bool)
+	for _, inst := range instances {
+		hosts[inst.Hostname] = true
+		s.Services[inst.Name]++
+		if s.Versions[inst.Name] == nil {
+			s.Versions[inst.Name] = make(map[uint64]int)
+		}
+		s.Versions[inst.Name][inst.Version]++
+		s.AzNames[inst.AzName]++
+		s.MachineClasses[inst.MachineClass]++
+	}
+	s.Hosts = len(hosts)
+
+	return s
+}
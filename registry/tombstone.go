@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const (
+	tombstoneNode  = "/discovery-service-tombstones"
+	tombstonePath  = "/discovery-service-tombstones/%v"
+	tombstoneGrace = 2 * maxHeartbeatDiff
+)
+
+// Reason explains why an instance went away
+type Reason int
+
+const (
+	ReasonUnknown Reason = iota
+	// ReasonUnregister is a clean shutdown, where the instance asked to be unregistered
+	ReasonUnregister
+	// ReasonHeartbeatTimeout is where the instance stopped responding to heartbeats
+	ReasonHeartbeatTimeout
+	// ReasonSessionExpiry is where the discovery node the instance registered with lost its ZK
+	// session, so we never got to find out what happened to the instance
+	ReasonSessionExpiry
+	// ReasonDrained is an unregister of an instance that had already been drained of traffic
+	ReasonDrained
+	// ReasonEviction is where the instance was removed by the leader or an administrator
+	ReasonEviction
+)
+
+var reasonNames = map[Reason]string{
+	ReasonUnknown:          "UNKNOWN",
+	ReasonUnregister:       "UNREGISTER",
+	ReasonHeartbeatTimeout: "HEARTBEAT_TIMEOUT",
+	ReasonSessionExpiry:    "SESSION_EXPIRY",
+	ReasonDrained:          "DRAINED",
+	ReasonEviction:         "ADMIN_EVICTION",
+}
+
+// String satisfies Stringer, using the same names as servicedown
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return reasonNames[ReasonUnknown]
+}
+
+// tombstone records why an instance went away, written by whichever discovery node removes it
+// so that everyone can include the reason when they announce the instance has gone. Tombstones
+// outlive the instance for a short while, for every node to read, before the leader reaps them.
+type tombstone struct {
+	Reason        Reason
+	LastHeartbeat time.Time
+}
+
+// buryInstance writes a tombstone for an instance that is about to be removed
+func buryInstance(instanceId string, t *tombstone) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal tombstone JSON: %v", err)
+	}
+
+	path := zkPathForTombstone(instanceId)
+	_, err = zk.Create(path, b, 0, acls.forPath(path))
+	if err == gozk.ErrNodeExists {
+		_, err = zk.Set(path, b, -1)
+	}
+	return err
+}
+
+// tombstoneOf looks up why an instance went away; it is readTombstone, other than in tests
+var tombstoneOf = readTombstone
+
+// readTombstone reads the tombstone for an instance that has gone; if there is none then nobody
+// removed it deliberately, so its owner's ZK session must have expired
+func readTombstone(instanceId string) *tombstone {
+	path := zkPathForTombstone(instanceId)
+	b, _, err := zk.Get(path)
+	if err == gozk.ErrNoNode {
+		return &tombstone{Reason: ReasonSessionExpiry}
+	} else if err != nil {
+		log.Warnf("[Discovery] Failed to read tombstone for %v: %v", instanceId, err)
+		return &tombstone{Reason: ReasonUnknown}
+	}
+
+	t := &tombstone{}
+	if err := json.Unmarshal(b, t); err != nil {
+		log.Warnf("[Discovery] Failed to unmarshal tombstone for %v: %v", instanceId, err)
+		t.Reason = ReasonUnknown
+	}
+	return t
+}
+
+// unburyInstance removes any tombstone for an instance, eg: when it registers again
+func unburyInstance(instanceId string) error {
+	err := zk.Delete(zkPathForTombstone(instanceId), -1)
+	if err == gozk.ErrNoNode {
+		return nil
+	}
+	return err
+}
+
+// reapTombstones removes tombstones once every node has had a chance to read them
+func reapTombstones() error {
+	ids, _, err := zk.Children(tombstoneNode)
+	if err != nil {
+		return err
+	}
+
+	cutOff := time.Now().Add(-tombstoneGrace)
+	for _, id := range ids {
+		path := zkPathForTombstone(id)
+		exists, stat, err := zk.Exists(path)
+		if err != nil {
+			return err
+		}
+		if !exists || time.Unix(0, stat.Mtime*int64(time.Millisecond)).After(cutOff) {
+			continue
+		}
+		if err := zk.Delete(path, stat.Version); err != nil && err != gozk.ErrNoNode {
+			log.Warnf("[Discovery] Failed to reap tombstone for %v: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// zkPathForTombstone gets tombstone path for an id
+func zkPathForTombstone(instanceId string) string {
+	return fmt.Sprintf(tombstonePath, instanceId)
+}
@@ -1,10 +1,27 @@
 package registry
 
 import (
+	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 )
 
+const (
+	// MaxWeight is the largest weight an instance can carry, representing 100% of its share of traffic
+	MaxWeight = 100
+	// DefaultWeight is given to any instance that doesn't specify otherwise
+	DefaultWeight = MaxWeight
+	// MaxLabels is the most labels an instance can carry
+	MaxLabels = 16
+	// MaxLabelValue is the longest a label's value can be
+	MaxLabelValue = 255
+)
+
+// validLabelKey is what we accept as a label's key
+var validLabelKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
 // Sla defines how we expect an instance to perform in terms of response times, resource usage etc.
 type Sla struct {
 	// Mean is the mean avg response time (time to generate response) promised for this endpoint
@@ -37,6 +54,23 @@ type Instance struct {
 	OwnerTeam    string
 	Version      uint64
 	Endpoints    []*Endpoint
+	// Weight is the relative share of traffic this instance should receive, from 1 to MaxWeight;
+	// zero means DefaultWeight, so documents written before weights existed are unaffected
+	Weight uint32
+	// Drained instances remain registered but should no longer be sent new traffic
+	Drained bool
+	// Labels are free-form metadata, eg: "track": "canary", set when registering or adjusted afterwards;
+	// omitted when empty, so documents written before labels existed are unaffected
+	Labels map[string]string `json:",omitempty"`
+	// Region is the region this instance was discovered in; assigned on sync rather than stored
+	Region string `json:"-"`
+	// Registered is when the instance's node was created in ZK; likewise assigned on sync
+	Registered time.Time `json:"-"`
+
+	// schema is the schema version of the document this was read from, and unknown holds any fields
+	// from it that we don't understand (written by newer discovery nodes), so we can write them back
+	schema  uint32
+	unknown map[string]json.RawMessage
 }
 
 // GetSubTopics returns a list of the Subscribe topics for each Endpoint this
@@ -50,6 +84,36 @@ func (inst Instance) GetSubTopics() []string {
 	return topics
 }
 
+// GetWeight returns the effective weight of this instance, between 1 and MaxWeight
+func (inst Instance) GetWeight() uint32 {
+	switch {
+	case inst.Weight == 0:
+		return DefaultWeight
+	case inst.Weight > MaxWeight:
+		return MaxWeight
+	}
+	return inst.Weight
+}
+
+// ValidLabel returns whether key and value make a label we accept: a key of up to 63 letters, digits,
+// '.', '_' and '-', starting with a letter or digit, and a value of 1 to MaxLabelValue characters
+func ValidLabel(key, value string) bool {
+	return validLabelKey.MatchString(key) && value != "" && len(value) <= MaxLabelValue
+}
+
+// sameLabels returns whether two sets of labels are the same, treating nil and empty alike
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Instances represents a list of instances
 type Instances []*Instance
 
@@ -90,14 +154,31 @@ func MatchingAz(az string) Filter {
 	}
 }
 
+// MatchingOwnerTeam filter by owning team (case insensitive)
+func MatchingOwnerTeam(team string) Filter {
+	return func(inst *Instance) bool {
+		return !strings.EqualFold(inst.OwnerTeam, team)
+	}
+}
+
+// MatchingOwnerEmail filter by owner's email address (case insensitive)
+func MatchingOwnerEmail(email string) Filter {
+	return func(inst *Instance) bool {
+		return !strings.EqualFold(inst.OwnerEmail, email)
+	}
+}
+
 // ---
 
-// zkPath yields the ZK path
+// zkPath yields the ZK path to register a new instance at, in whichever layout is configured
 func (i *Instance) zkPath() string {
+	if hierarchical {
+		return zkPathForServiceInstance(i.Name, i.Id)
+	}
 	return zkPathForInstance(i.Id)
 }
 
-// zkPathForInstance gets path for an id
+// zkPathForInstance gets path for an id, in the flat layout
 func zkPathForInstance(instanceId string) string {
 	return fmt.Sprintf(instanceNode, instanceId)
 }
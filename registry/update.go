@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const updateAttempts = 5
+
+var (
+	// ErrInstanceNotFound is returned when updating an instance that isn't registered
+	ErrInstanceNotFound = fmt.Errorf("Instance not found")
+	// ErrInvalidWeight is returned when a weight is outside of 1 to MaxWeight
+	ErrInvalidWeight = fmt.Errorf("Weight must be between 1 and %v", MaxWeight)
+	// ErrInvalidLabels is returned when setting a label ValidLabel rejects, or too many labels
+	ErrInvalidLabels = fmt.Errorf("Labels must have valid keys, values of no more than %v characters, and number no more than %v", MaxLabelValue, MaxLabels)
+	// ErrRegistrationConflict is returned when registering an instance ID that is already registered
+	// differently, without asking to update it
+	ErrRegistrationConflict = fmt.Errorf("Instance is already registered differently; register with update to replace it")
+
+	// errUnchanged can be returned by an update to indicate there is nothing to write
+	errUnchanged = fmt.Errorf("Instance unchanged")
+	// errMoved aborts an update of an instance that needs moving instead
+	errMoved = fmt.Errorf("Instance moved")
+)
+
+// readInstance returns our copy of an instance, or reads its stored document if we haven't synced it yet
+func readInstance(instanceId string) (*Instance, error) {
+	if inst := region.singleInstance(instanceId); inst != nil {
+		return inst, nil
+	}
+	if !ValidNodeName(instanceId) {
+		// couldn't have been registered, and mustn't be used as a path
+		return nil, ErrInstanceNotFound
+	}
+
+	b, _, err := zk.Get(instancePath(instanceId))
+	if err == gozk.ErrNoNode {
+		return nil, ErrInstanceNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeInstance(b)
+}
+
+// updateInstance applies fn to the stored document for an instance, retrying if someone else
+// modifies the document between us reading and writing it; fn may abort the update by returning
+// an error, or errUnchanged if there is nothing to do
+func updateInstance(instanceId string, fn func(inst *Instance) error) error {
+	if !ValidNodeName(instanceId) {
+		return ErrInstanceNotFound
+	}
+	return updateInstanceAt(instancePath(instanceId), instanceId, fn)
+}
+
+// updateInstanceAt is updateInstance for when we know where the instance is stored
+func updateInstanceAt(path, instanceId string, fn func(inst *Instance) error) error {
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		b, stat, err := zk.Get(path)
+		if err == gozk.ErrNoNode {
+			return ErrInstanceNotFound
+		} else if err != nil {
+			return err
+		}
+
+		inst, err := decodeInstance(b)
+		if err != nil {
+			return err
+		}
+		if err := fn(inst); err == errUnchanged {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if b, err = encodeInstance(inst); err != nil {
+			return err
+		}
+
+		stat, err = zk.Set(path, b, stat.Version)
+		if err == gozk.ErrBadVersion {
+			log.Debugf("[Discovery] Instance %v changed whilst updating, retrying", instanceId)
+			continue
+		} else if err == gozk.ErrNoNode {
+			return ErrInstanceNotFound
+		} else if err != nil {
+			return err
+		}
+
+		// every node will pick this up via its data watch, but there's no need for us to wait
+		region.update(inst, stat)
+		return nil
+	}
+
+	return fmt.Errorf("Failed to update %v after %v attempts", instanceId, updateAttempts)
+}
+
+// sameRegistration returns whether two documents describe the same registration, ignoring state
+// that is adjusted after registering (weight, drain and labels)
+func sameRegistration(a, b *Instance) bool {
+	x, y := *a, *b
+	x.Weight, y.Weight = 0, 0
+	x.Drained, y.Drained = false, false
+	x.Labels, y.Labels = nil, nil
+	xb, err := json.Marshal(&x)
+	if err != nil {
+		return false
+	}
+	yb, err := json.Marshal(&y)
+	if err != nil {
+		return false
+	}
+	return string(xb) == string(yb)
+}
@@ -0,0 +1,214 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const (
+	versionsNode = "/discovery-service-versions"
+	versionsPath = "/discovery-service-versions/%v@%v"
+	// versionRetention is how long we remember a version after its last instance has gone, so
+	// that a version which briefly has no instances (eg: during a redeploy) keeps its first seen
+	versionRetention = 24 * time.Hour
+)
+
+// ServiceVersion describes where a single version of a service is running
+type ServiceVersion struct {
+	Version   uint64
+	Instances int
+	// AzNames counts instances of this version within each AZ
+	AzNames map[string]int
+	// FirstSeen is when this version was first seen running in the region, which is recorded by
+	// the leader so that it survives the version's instances being replaced
+	FirstSeen time.Time
+	// Newest is set on the highest running version
+	Newest bool
+}
+
+// seenVersion is what the leader records about a version of a service
+type seenVersion struct {
+	FirstSeen time.Time
+}
+
+// Versions groups instances by version, highest (newest) first; instances would normally all be of
+// the same service, eg: via Filter(MatchingService(name)). FirstSeen is when the longest running
+// instance registered; see ServiceVersions for when each version was first seen.
+func (is Instances) Versions() []*ServiceVersion {
+	byVersion := make(map[uint64]*ServiceVersion)
+	vs := make(versions, 0)
+	for _, inst := range is {
+		sv, ok := byVersion[inst.Version]
+		if !ok {
+			sv = &ServiceVersion{
+				Version:   inst.Version,
+				AzNames:   make(map[string]int),
+				FirstSeen: inst.Registered,
+			}
+			byVersion[inst.Version] = sv
+			vs = append(vs, inst.Version)
+		}
+		sv.Instances++
+		sv.AzNames[inst.AzName]++
+		if inst.Registered.Before(sv.FirstSeen) {
+			sv.FirstSeen = inst.Registered
+		}
+	}
+	sort.Sort(sort.Reverse(vs))
+
+	ret := make([]*ServiceVersion, len(vs))
+	for i, v := range vs {
+		ret[i] = byVersion[v]
+	}
+	if len(ret) > 0 {
+		ret[0].Newest = true
+	}
+	return ret
+}
+
+// ServiceVersions returns each running version of a service in our region, newest first, with
+// when the leader first saw it running; versions the leader hasn't recorded yet fall back to when
+// their longest running instance registered
+func ServiceVersions(service string) []*ServiceVersion {
+	vs := AllInstances().Filter(MatchingService(service)).Versions()
+	for _, sv := range vs {
+		if seen := versionSeen(service, sv.Version); seen != nil && seen.FirstSeen.Before(sv.FirstSeen) {
+			sv.FirstSeen = seen.FirstSeen
+		}
+	}
+	return vs
+}
+
+// versionSeen is readVersion, other than in tests
+var versionSeen = readVersion
+
+// readVersion reads what the leader recorded about a version, or nil if nothing usable is recorded
+func readVersion(service string, version uint64) *seenVersion {
+	b, _, err := zk.Get(zkPathForVersion(service, version))
+	if err == gozk.ErrNoNode {
+		return nil
+	} else if err != nil {
+		log.Warnf("[Discovery] Failed to read first seen for %v@%v: %v", service, version, err)
+		return nil
+	}
+
+	v := &seenVersion{}
+	if err := json.Unmarshal(b, v); err != nil {
+		log.Warnf("[Discovery] Failed to unmarshal first seen for %v@%v: %v", service, version, err)
+		return nil
+	}
+	return v
+}
+
+// versionRecorder is run by the leader to record when each version of a service is first seen
+// running in the region, and to forget versions that have been gone for versionRetention
+type versionRecorder struct {
+	// gone is when we first noticed each recorded version had no instances; it is only kept in
+	// memory, so a new leader starts the retention period afresh
+	gone map[string]time.Time
+}
+
+func newVersionRecorder() *versionRecorder {
+	ensureNode(versionsNode)
+	return &versionRecorder{
+		gone: make(map[string]time.Time),
+	}
+}
+
+// record is a leader task that compares the running versions with those recorded in ZK
+func (vr *versionRecorder) record() error {
+	// until we have synced, we can't tell which versions are running
+	if !region.isSynced() {
+		return nil
+	}
+
+	recorded, _, err := zk.Children(versionsNode)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(recorded))
+	for _, name := range recorded {
+		known[name] = true
+	}
+
+	// the earliest registration of each running version, for those not recorded yet
+	running := make(map[string]bool)
+	unrecorded := make(map[string]*Instance)
+	for _, inst := range AllInstances() {
+		name := versionName(inst.Name, inst.Version)
+		running[name] = true
+		delete(vr.gone, name)
+		if known[name] {
+			continue
+		}
+		if first, ok := unrecorded[name]; !ok || inst.Registered.Before(first.Registered) {
+			unrecorded[name] = inst
+		}
+	}
+	for name, inst := range unrecorded {
+		if err := writeVersion(inst.Name, inst.Version, &seenVersion{FirstSeen: inst.Registered}); err != nil {
+			log.Warnf("[Discovery] Failed to record first seen for %v: %v", name, err)
+		}
+	}
+
+	now := time.Now()
+	for _, name := range recorded {
+		if running[name] {
+			continue
+		}
+		since, ok := vr.gone[name]
+		if !ok {
+			vr.gone[name] = now
+			continue
+		}
+		if now.Sub(since) < versionRetention {
+			continue
+		}
+		if err := zk.Delete(versionsNode+"/"+name, -1); err != nil && err != gozk.ErrNoNode {
+			log.Warnf("[Discovery] Failed to forget version %v: %v", name, err)
+			continue
+		}
+		delete(vr.gone, name)
+	}
+	for name := range vr.gone {
+		if !known[name] {
+			delete(vr.gone, name)
+		}
+	}
+
+	return nil
+}
+
+// writeVersion records when a version was first seen, unless someone already has
+func writeVersion(service string, version uint64, v *seenVersion) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal first seen JSON: %v", err)
+	}
+
+	path := zkPathForVersion(service, version)
+	_, err = zk.Create(path, b, 0, acls.forPath(path))
+	if err == gozk.ErrNodeExists {
+		return nil
+	}
+	return err
+}
+
+// versionName is how a version of a service is named within versionsNode; service names can't
+// contain "@" (see ValidNodeName)
+func versionName(service string, version uint64) string {
+	return service + "@" + strconv.FormatUint(version, 10)
+}
+
+// zkPathForVersion gets the path recording when a version of a service was first seen
+func zkPathForVersion(service string, version uint64) string {
+	return fmt.Sprintf(versionsPath, service, version)
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceVersionsFirstSeen(t *testing.T) {
+	rg, seen := region, versionSeen
+	defer func() {
+		region, versionSeen = rg, seen
+	}()
+
+	started := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	region = emptyRegionReg("test")
+	region.synced = true
+	for _, inst := range []*Instance{
+		{Id: "a", Name: "com.HailoOSS.service.foo", Version: 1, Registered: started.Add(time.Hour)},
+		{Id: "b", Name: "com.HailoOSS.service.foo", Version: 1, Registered: started.Add(2 * time.Hour)},
+		{Id: "c", Name: "com.HailoOSS.service.foo", Version: 2, Registered: started.Add(3 * time.Hour)},
+		{Id: "d", Name: "com.HailoOSS.service.bar", Version: 1, Registered: started},
+	} {
+		region.instances[inst.Id] = inst
+	}
+
+	// version 1 has been running since before any of its current instances; version 2 isn't recorded yet
+	versionSeen = func(service string, version uint64) *seenVersion {
+		if service == "com.HailoOSS.service.foo" && version == 1 {
+			return &seenVersion{FirstSeen: started}
+		}
+		return nil
+	}
+
+	vs := ServiceVersions("com.HailoOSS.service.foo")
+	if len(vs) != 2 {
+		t.Fatalf("Expected 2 versions, got %v", len(vs))
+	}
+	if vs[0].Version != 2 || !vs[0].Newest || vs[0].Instances != 1 {
+		t.Errorf("Expected version 2 to be newest with 1 instance, got %+v", vs[0])
+	}
+	if !vs[0].FirstSeen.Equal(started.Add(3 * time.Hour)) {
+		t.Errorf("Expected unrecorded version to fall back to its earliest registration, got %v", vs[0].FirstSeen)
+	}
+	if vs[1].Version != 1 || vs[1].Instances != 2 {
+		t.Errorf("Expected version 1 with 2 instances, got %+v", vs[1])
+	}
+	if !vs[1].FirstSeen.Equal(started) {
+		t.Errorf("Expected recorded first seen %v, got %v", started, vs[1].FirstSeen)
+	}
+}
@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/nu7hatch/gouuid"
+
+	gozk "github.com/HailoOSS/go-zookeeper/zk"
+	"github.com/HailoOSS/protobuf/proto"
+	zk "github.com/HailoOSS/service/zookeeper"
+)
+
+const (
+	webhookNode = "/discovery-service-webhooks"
+	webhookPath = "/discovery-service-webhooks/%v"
+
+	webhookTimeout    = 5 * time.Second
+	webhookAttempts   = 8
+	webhookBackoff    = time.Second
+	webhookMaxBackoff = time.Minute
+
+	// SignatureHeader carries the hex-encoded HMAC-SHA256 of the request body, keyed with the hook's secret
+	SignatureHeader = "X-Discovery-Signature"
+	// TopicHeader carries the topic the event would have been published on
+	TopicHeader = "X-Discovery-Topic"
+)
+
+var (
+	// ErrWebhookNotFound is returned when deleting a webhook that doesn't exist
+	ErrWebhookNotFound = fmt.Errorf("Webhook not found")
+	// ErrInvalidWebhook is returned when creating a webhook without a valid http(s) URL or secret
+	ErrInvalidWebhook = fmt.Errorf("Webhook requires an http(s) URL and a secret")
+	// ErrWebhookInsecure is returned when creating a webhook without ZK credentials, since its secret
+	// would be readable by anyone
+	ErrWebhookInsecure = fmt.Errorf("Webhooks require ZK credentials to keep their secrets")
+
+	webhookClient = &http.Client{Timeout: webhookTimeout}
+)
+
+// Webhook is an HTTP endpoint that receives serviceup/servicedown events as JSON
+type Webhook struct {
+	Id  string
+	Url string
+	// Secret is used to sign each request, so receivers can check it came from us
+	Secret string
+	// ServicePrefix restricts events to services whose name starts with it; empty matches everything
+	ServicePrefix string
+}
+
+// webhookBody is what we POST to each webhook
+type webhookBody struct {
+	Topic     string        `json:"topic"`
+	Timestamp int64         `json:"timestamp"`
+	Event     proto.Message `json:"event"`
+}
+
+// webhookPublisher POSTs events to a single webhook
+type webhookPublisher struct {
+	hook *Webhook
+}
+
+func (p *webhookPublisher) publish(topic string, payload proto.Message) error {
+	// don't bother delivering to hooks that have since been deleted
+	if hooks.get(p.hook.Id) == nil {
+		return errDiscard
+	}
+
+	b, err := json.Marshal(&webhookBody{
+		Topic:     topic,
+		Timestamp: time.Now().Unix(),
+		Event:     payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", p.hook.Url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TopicHeader, topic)
+	req.Header.Set(SignatureHeader, signWebhook(p.hook.Secret, b))
+
+	rsp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("Webhook %v responded %v", p.hook.Id, rsp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the hex-encoded HMAC-SHA256 of body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ---
+
+type registeredHook struct {
+	hook *Webhook
+	out  *outbox
+}
+
+type webhookReg struct {
+	sync.RWMutex
+	hooks map[string]*registeredHook
+}
+
+func newWebhookReg() *webhookReg {
+	r := &webhookReg{
+		hooks: make(map[string]*registeredHook),
+	}
+
+	ensureNode(webhookNode)
+
+	go r.syncer()
+
+	return r
+}
+
+// syncer reloads webhooks whenever one is created or deleted; hooks are never modified in place
+func (r *webhookReg) syncer() {
+	log.Debug("[Discovery] Launching webhook syncer...")
+	for {
+		ids, _, watch, err := zk.ChildrenW(webhookNode)
+		if err != nil {
+			log.Warnf("[Discovery] Failed to read webhooks: %v -- delaying for %v", err, initDelay)
+			time.Sleep(initDelay)
+			continue
+		}
+
+		if err := r.sync(ids); err != nil {
+			log.Warnf("[Discovery] Failed to sync webhooks: %v", err)
+		}
+
+		e := <-watch
+		log.Debugf("[Discovery] Webhook watch triggered for event %v", e)
+	}
+}
+
+func (r *webhookReg) sync(ids []string) error {
+	hooks := make(map[string]*registeredHook)
+	for _, id := range ids {
+		if h := r.getRegistered(id); h != nil {
+			hooks[id] = h
+			continue
+		}
+
+		b, _, err := zk.Get(zkPathForWebhook(id))
+		if err == gozk.ErrNoNode {
+			continue
+		} else if err != nil {
+			return err
+		}
+		hook := &Webhook{}
+		if err := json.Unmarshal(b, hook); err != nil {
+			return err
+		}
+		out := newOutbox(&webhookPublisher{hook: hook}, webhookAttempts, webhookBackoff, webhookMaxBackoff)
+		out.name = "webhook"
+		out.desc = "Webhook " + id
+		hooks[id] = &registeredHook{hook: hook, out: out}
+	}
+
+	r.Lock()
+	r.hooks = hooks
+	r.Unlock()
+
+	return nil
+}
+
+func (r *webhookReg) getRegistered(id string) *registeredHook {
+	r.RLock()
+	defer r.RUnlock()
+	return r.hooks[id]
+}
+
+// get returns a webhook by ID, or nil
+func (r *webhookReg) get(id string) *Webhook {
+	if h := r.getRegistered(id); h != nil {
+		return h.hook
+	}
+	return nil
+}
+
+// list returns all webhooks
+func (r *webhookReg) list() []*Webhook {
+	r.RLock()
+	defer r.RUnlock()
+	ret := make([]*Webhook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		ret = append(ret, h.hook)
+	}
+	return ret
+}
+
+// notify queues an event for every webhook interested in service; key orders events as per outbox
+func (r *webhookReg) notify(service, key, topic string, payload proto.Message) {
+	r.RLock()
+	defer r.RUnlock()
+	for _, h := range r.hooks {
+		if strings.HasPrefix(service, h.hook.ServicePrefix) {
+			h.out.enqueue(key, topic, payload)
+		}
+	}
+}
+
+// createWebhook stores a new webhook, assigning it an ID
+func createWebhook(hook *Webhook) (*Webhook, error) {
+	u, err := url.Parse(hook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || hook.Secret == "" {
+		return nil, ErrInvalidWebhook
+	}
+	if !acls.keepsSecrets() {
+		return nil, ErrWebhookInsecure
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	hook.Id = id.String()
+
+	b, err := json.Marshal(hook)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal webhook JSON: %v", err)
+	}
+	if _, err := zk.Create(zkPathForWebhook(hook.Id), b, 0, acls.forPath(webhookNode)); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// deleteWebhook removes a webhook by ID
+func deleteWebhook(id string) error {
+	err := zk.Delete(zkPathForWebhook(id), -1)
+	if err == gozk.ErrNoNode {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// zkPathForWebhook gets path for a webhook id
+func zkPathForWebhook(id string) string {
+	return fmt.Sprintf(webhookPath, id)
+}